@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+var attachCommand = cli.Command{
+	Name:        "attach",
+	Usage:       "Attach to the detached child",
+	ArgsUsage:   "[flags]",
+	Description: "Attach the stdin, stdout, and stderr to the child launched with \"rootlesskit --detach\". Press Ctrl-C to detach.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-stdin",
+			Usage: "Do not attach stdin",
+		},
+	},
+	Action: attachAction,
+}
+
+func attachAction(clicontext *cli.Context) error {
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	var stdin io.Reader = os.Stdin
+	if clicontext.Bool("no-stdin") {
+		stdin = nil
+	}
+	return c.Attach(context.Background(), stdin, os.Stdout, os.Stderr)
+}
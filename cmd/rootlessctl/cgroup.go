@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var cgroupCommand = cli.Command{
+	Name:      "cgroup",
+	Usage:     "Show the limits and usage of the cgroup",
+	ArgsUsage: "[flags]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints as JSON",
+		},
+	},
+	Action: cgroupAction,
+}
+
+func cgroupAction(clicontext *cli.Context) error {
+	w := clicontext.App.Writer
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	cg, err := c.Cgroup(context.Background())
+	if err != nil {
+		return err
+	}
+	if clicontext.Bool("json") {
+		m, err := json.MarshalIndent(cg, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(m))
+		return nil
+	}
+	fmt.Fprintf(w, "- Path: %s\n", cg.Path)
+	fmt.Fprintf(w, "- Controllers: %v\n", cg.Controllers)
+	if cg.MemoryMax != "" {
+		fmt.Fprintf(w, "- Memory: %d bytes (max: %s)\n", cg.MemoryCurrent, cg.MemoryMax)
+	}
+	if cg.CPUMax != "" {
+		fmt.Fprintf(w, "- CPU: %d usec (max: %s)\n", cg.CPUUsageUsec, cg.CPUMax)
+	}
+	if cg.PidsMax != "" {
+		fmt.Fprintf(w, "- PIDs: %d (max: %s)\n", cg.PidsCurrent, cg.PidsMax)
+	}
+	if cg.IOWeight != 0 {
+		fmt.Fprintf(w, "- IO weight: %d\n", cg.IOWeight)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+)
+
+var copyUpCommand = cli.Command{
+	Name:  "copy-up",
+	Usage: "Manage the copy-up directories",
+	Subcommands: []*cli.Command{
+		&copyUpDiffCommand,
+	},
+}
+
+var copyUpDiffCommand = cli.Command{
+	Name:        "diff",
+	Usage:       "List the changed files in the persistent copy-up directories",
+	ArgsUsage:   "[flags]",
+	Description: "List the changed files in the copy-up directories specified as \"--copy-up=DIR:persist=PERSISTDIR\". \"A\" stands for added, \"C\" for changed, and \"D\" for deleted.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints as JSON",
+		},
+	},
+	Action: copyUpDiffAction,
+}
+
+func copyUpDiffAction(clicontext *cli.Context) error {
+	w := clicontext.App.Writer
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	changes, err := c.CopyUpDiff(context.Background())
+	if err != nil {
+		return err
+	}
+	if clicontext.Bool("json") {
+		m, err := json.MarshalIndent(changes, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(m))
+		return nil
+	}
+	for _, ch := range changes {
+		k := "?"
+		switch ch.Kind {
+		case api.CopyUpChangeAdded:
+			k = "A"
+		case api.CopyUpChangeChanged:
+			k = "C"
+		case api.CopyUpChangeDeleted:
+			k = "D"
+		case api.CopyUpChangeUnreadable:
+			k = "?"
+		}
+		fmt.Fprintf(w, "%s %s\n", k, ch.Path)
+	}
+	return nil
+}
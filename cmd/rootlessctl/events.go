@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+)
+
+var eventsCommand = cli.Command{
+	Name:      "events",
+	Usage:     "Stream events",
+	ArgsUsage: "[flags]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Prints as newline-delimited JSON",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "Show the recent past events since the timestamp (RFC 3339) or the relative duration (e.g. \"10m\")",
+		},
+	},
+	Action: eventsAction,
+}
+
+func eventsAction(clicontext *cli.Context) error {
+	w := clicontext.App.Writer
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if s := clicontext.String("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			since = time.Now().Add(-d)
+		} else if since, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return fmt.Errorf("failed to parse since %q: %w", s, err)
+		}
+	}
+	jsonMode := clicontext.Bool("json")
+	return c.Events(context.Background(), since, func(ev *events.Event) error {
+		if jsonMode {
+			m, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(m))
+			return nil
+		}
+		fmt.Fprintf(w, "%s %s", ev.Time.Format(time.RFC3339Nano), ev.Type)
+		switch ev.Type {
+		case events.TypeNetworkConfigured:
+			if ev.Network != nil {
+				fmt.Fprintf(w, " driver=%s", ev.Network.Driver)
+				if ev.Network.ChildIP != nil {
+					fmt.Fprintf(w, " ip=%s", ev.Network.ChildIP)
+				}
+			}
+		case events.TypePortAdded, events.TypePortRemoved:
+			if ev.Port != nil {
+				spec := ev.Port.Spec
+				fmt.Fprintf(w, " id=%d", ev.Port.ID)
+				if spec.Proto != "" {
+					fmt.Fprintf(w, " proto=%s parent=%s child=%s", spec.Proto,
+						net.JoinHostPort(spec.ParentIP, strconv.Itoa(spec.ParentPort)),
+						net.JoinHostPort(spec.ChildIP, strconv.Itoa(spec.ChildPort)))
+				}
+			}
+		case events.TypeChildExited:
+			if ev.ExitCode != nil {
+				fmt.Fprintf(w, " code=%d", *ev.ExitCode)
+			}
+			if ev.Signal != "" {
+				fmt.Fprintf(w, " signal=%q", ev.Signal)
+			}
+		case events.TypeHelperExited:
+			fmt.Fprintf(w, " helper=%s", ev.Helper)
+		}
+		if ev.Message != "" {
+			fmt.Fprintf(w, " message=%q", ev.Message)
+		}
+		fmt.Fprintln(w)
+		return nil
+	})
+}
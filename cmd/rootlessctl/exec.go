@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/pty"
+)
+
+var execCommand = cli.Command{
+	Name:        "exec",
+	Usage:       "Execute a command in the namespaces",
+	ArgsUsage:   "[flags] -- COMMAND [ARG...]",
+	Description: "Execute a command in the user, mount, network, PID, UTS, IPC, and cgroup namespaces of the RootlessKit child. Requires nsenter(1) on the host.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "tty",
+			Aliases: []string{"t"},
+			Usage:   "Allocate a pseudo-TTY",
+		},
+		&cli.StringSliceFlag{
+			Name:    "env",
+			Aliases: []string{"e"},
+			Usage:   "Set environment variables, e.g. \"FOO=bar\"",
+		},
+		&cli.StringFlag{
+			Name:    "workdir",
+			Aliases: []string{"w"},
+			Usage:   "Working directory inside the namespaces",
+		},
+	},
+	Action: execAction,
+}
+
+func execAction(clicontext *cli.Context) error {
+	if clicontext.NArg() < 1 {
+		return errors.New("no command specified")
+	}
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	req := &api.ExecRequest{
+		Args:       clicontext.Args().Slice(),
+		Env:        clicontext.StringSlice("env"),
+		WorkingDir: clicontext.String("workdir"),
+		TTY:        clicontext.Bool("tty"),
+	}
+	if req.TTY {
+		if !pty.IsTerminal(os.Stdin) {
+			return errors.New("the input device is not a TTY")
+		}
+		req.Width, req.Height, err = pty.Size(os.Stdin)
+		if err != nil {
+			logrus.WithError(err).Warn("failed to get the terminal size")
+		}
+		restore, err := pty.MakeRaw(os.Stdin)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := restore(); err != nil {
+				logrus.WithError(err).Warn("failed to restore the terminal")
+			}
+		}()
+	}
+	code, err := c.Exec(context.Background(), req, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return cli.Exit("", code)
+	}
+	return nil
+}
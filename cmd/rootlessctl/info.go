@@ -44,6 +44,17 @@ func infoAction(clicontext *cli.Context) error {
 	fmt.Fprintf(w, "- Implementation version: %s\n", info.Version)
 	fmt.Fprintf(w, "- State Directory: %s\n", info.StateDir)
 	fmt.Fprintf(w, "- Child PID: %d\n", info.ChildPID)
+	if info.TargetRestarts > 0 {
+		fmt.Fprintf(w, "- Target command restarts: %d\n", info.TargetRestarts)
+	}
+	if info.TimeNamespace != nil {
+		fmt.Fprintf(w, "- Time namespace:\n")
+		fmt.Fprintf(w, "  - Monotonic offset: %v\n", info.TimeNamespace.MonotonicOffset)
+		fmt.Fprintf(w, "  - Boottime offset: %v\n", info.TimeNamespace.BoottimeOffset)
+	}
+	if info.Landlock != nil {
+		fmt.Fprintf(w, "- Landlock ABI: %d\n", info.Landlock.ABI)
+	}
 	if info.NetworkDriver != nil {
 		fmt.Fprintf(w, "- Network Driver: %s\n", info.NetworkDriver.Driver)
 		fmt.Fprintf(w, "  - DNS: %v\n", info.NetworkDriver.DNS)
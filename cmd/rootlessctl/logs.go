@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v2"
+)
+
+var logsCommand = cli.Command{
+	Name:        "logs",
+	Usage:       "Show the log of the detached child",
+	ArgsUsage:   "[flags]",
+	Description: "Show the stdout and the stderr of the child launched with \"rootlesskit --detach\".",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "Follow the log output",
+		},
+	},
+	Action: logsAction,
+}
+
+func logsAction(clicontext *cli.Context) error {
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	return c.Logs(context.Background(), clicontext.Bool("follow"), clicontext.App.Writer)
+}
@@ -35,6 +35,16 @@ func main() {
 		&addPortsCommand,
 		&removePortsCommand,
 		&infoCommand,
+		&execCommand,
+		&attachCommand,
+		&logsCommand,
+		&eventsCommand,
+		&shutdownCommand,
+		&sysctlCommand,
+		&cgroupCommand,
+		&pauseCommand,
+		&resumeCommand,
+		&copyUpCommand,
 	}
 	app.Before = func(clicontext *cli.Context) error {
 		if debug {
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v2"
+)
+
+var pauseCommand = cli.Command{
+	Name:        "pause",
+	Usage:       "Freeze the processes of the child",
+	ArgsUsage:   "[flags]",
+	Description: "Freeze the processes of the child with the cgroup2 freezer. Requires \"rootlesskit --cgroup-freezer\".\nNew TCP connections to the ports of the builtin port driver are closed, and UDP datagrams are dropped while frozen.",
+	Action:      pauseAction,
+}
+
+func pauseAction(clicontext *cli.Context) error {
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	return c.Freeze(context.Background())
+}
+
+var resumeCommand = cli.Command{
+	Name:      "resume",
+	Usage:     "Thaw the processes of the child",
+	ArgsUsage: "[flags]",
+	Action:    resumeAction,
+}
+
+func resumeAction(clicontext *cli.Context) error {
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	return c.Thaw(context.Background())
+}
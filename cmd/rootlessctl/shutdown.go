@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/urfave/cli/v2"
+)
+
+var shutdownCommand = cli.Command{
+	Name:        "shutdown",
+	Usage:       "Shut down RootlessKit gracefully",
+	ArgsUsage:   "[flags]",
+	Description: "Send the stop signal (\"rootlesskit --stop-signal\") to the child, and send SIGKILL after \"rootlesskit --stop-timeout\".\nThe command returns without waiting for the child to exit.",
+	Action:      shutdownAction,
+}
+
+func shutdownAction(clicontext *cli.Context) error {
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	return c.Shutdown(context.Background())
+}
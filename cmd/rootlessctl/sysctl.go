@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+var sysctlCommand = cli.Command{
+	Name:        "sysctl",
+	Usage:       "Get or set sysctls in the namespaces",
+	ArgsUsage:   "KEY[=VALUE]...",
+	Description: "Get or set sysctls in the namespaces of the child, e.g., \"rootlessctl sysctl net.ipv4.ping_group_range='0 2147483647'\".",
+	Action:      sysctlAction,
+}
+
+func sysctlAction(clicontext *cli.Context) error {
+	if clicontext.NArg() == 0 {
+		return errors.New("no sysctl specified")
+	}
+	w := clicontext.App.Writer
+	c, err := newClient(clicontext)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, arg := range clicontext.Args().Slice() {
+		key, value, set := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if set {
+			sysctl, err := c.SetSysctl(ctx, key, strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("failed to set %q: %w", key, err)
+			}
+			fmt.Fprintf(w, "%s = %s\n", sysctl.Key, sysctl.Value)
+			continue
+		}
+		sysctl, err := c.Sysctl(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to get %q: %w", key, err)
+		}
+		fmt.Fprintf(w, "%s = %s\n", sysctl.Key, sysctl.Value)
+	}
+	return nil
+}
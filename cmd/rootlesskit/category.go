@@ -17,7 +17,9 @@ const (
 	CategoryPort        = "Port"
 	CategoryMount       = "Mount"
 	CategoryProcess     = "Process"
+	CategorySecurity    = "Security"
 	CategorySubID       = "SubID"
+	CategoryHook        = "Hook"
 	CategoryMisc        = "Misc"
 )
 
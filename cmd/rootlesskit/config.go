@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+)
+
+// configIgnoredFlags cannot be specified in the config file, and are not printed with --print-config.
+var configIgnoredFlags = map[string]struct{}{
+	"config":       {},
+	"print-config": {},
+	"print-semver": {},
+	"help":         {},
+	"version":      {},
+}
+
+// configFlags returns the flags that can be specified in the config file, with their primary names.
+func configFlags(flags []cli.Flag) map[string]cli.Flag {
+	m := make(map[string]cli.Flag)
+	for _, f := range flags {
+		if x, ok := f.(*flag); ok {
+			f = x.Flag
+		}
+		name := f.Names()[0]
+		if _, ok := configIgnoredFlags[name]; ok {
+			continue
+		}
+		m[name] = f
+	}
+	return m
+}
+
+// loadConfig loads the TOML config file into clicontext.
+// The keys of the file correspond to the names of the flags.
+// The values of the flags that are set on the command line are not overridden.
+func loadConfig(clicontext *cli.Context, path string) error {
+	var m map[string]interface{}
+	if _, err := toml.DecodeFile(path, &m); err != nil {
+		return fmt.Errorf("failed to load the config file %q: %w", path, err)
+	}
+	flags := configFlags(clicontext.App.Flags)
+	for k, v := range m {
+		f, ok := flags[k]
+		if !ok {
+			return fmt.Errorf("config file %q: unknown key %q", path, k)
+		}
+		if clicontext.IsSet(k) {
+			continue
+		}
+		var values []interface{}
+		if _, isSlice := f.(*cli.StringSliceFlag); isSlice {
+			vv, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("config file %q: key %q must be an array", path, k)
+			}
+			values = vv
+		} else {
+			values = []interface{}{v}
+		}
+		for _, x := range values {
+			var s string
+			switch x := x.(type) {
+			case string:
+				s = x
+			case bool:
+				s = strconv.FormatBool(x)
+			case int64:
+				s = strconv.FormatInt(x, 10)
+			case float64:
+				s = strconv.FormatFloat(x, 'f', -1, 64)
+			default:
+				return fmt.Errorf("config file %q: key %q has an unexpected value %v (%T)", path, k, x, x)
+			}
+			if err := clicontext.Set(k, s); err != nil {
+				return fmt.Errorf("config file %q: key %q: invalid value %q: %w", path, k, s, err)
+			}
+		}
+	}
+	return nil
+}
+
+// printConfig prints the resolved flags as a TOML config file.
+// The flags that are not set and have the zero value are omitted,
+// as setting the zero value is not always the same as not setting the flag (e.g., --oom-score-adj).
+// The durations are printed as strings such as "1m30s", as TOML has no duration type.
+func printConfig(w io.Writer, clicontext *cli.Context) error {
+	m := make(map[string]interface{})
+	for name, f := range configFlags(clicontext.App.Flags) {
+		var v interface{}
+		switch f.(type) {
+		case *cli.StringSliceFlag:
+			v = clicontext.StringSlice(name)
+		case *cli.BoolFlag:
+			v = clicontext.Bool(name)
+		case *cli.IntFlag:
+			v = clicontext.Int(name)
+		case *cli.Uint64Flag:
+			v = clicontext.Uint64(name)
+		case *cli.DurationFlag:
+			v = clicontext.Duration(name)
+		default:
+			v = clicontext.String(name)
+		}
+		if !clicontext.IsSet(name) && reflect.ValueOf(v).IsZero() {
+			continue
+		}
+		switch x := v.(type) {
+		case []string:
+			if x == nil {
+				v = []string{}
+			}
+		case time.Duration:
+			v = x.String()
+		}
+		m[name] = v
+	}
+	return toml.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gotest.tools/v3/assert"
+)
+
+func testConfigFlags() []cli.Flag {
+	return []cli.Flag{
+		Categorize(&cli.StringFlag{Name: "config"}, CategoryMisc),
+		Categorize(&cli.BoolFlag{Name: "print-config"}, CategoryMisc),
+		Categorize(&cli.StringFlag{Name: "net", Value: "host"}, CategoryNetwork),
+		Categorize(&cli.StringSliceFlag{Name: "copy-up"}, CategoryMount),
+		Categorize(&cli.BoolFlag{Name: "pidns"}, CategoryProcess),
+		Categorize(&cli.IntFlag{Name: "oom-score-adj"}, CategoryProcess),
+		Categorize(&cli.Uint64Flag{Name: "cgroup-io-weight"}, CategoryProcess),
+		Categorize(&cli.DurationFlag{Name: "stop-timeout"}, CategoryProcess),
+	}
+}
+
+// runConfigApp runs an app with the config flags, and calls fn with the context after loading the config file.
+func runConfigApp(t *testing.T, args []string, fn func(*cli.Context) error) error {
+	t.Helper()
+	app := cli.NewApp()
+	app.Flags = testConfigFlags()
+	app.Writer = &bytes.Buffer{}
+	app.Before = func(clicontext *cli.Context) error {
+		if configPath := clicontext.String("config"); configPath != "" {
+			return loadConfig(clicontext, configPath)
+		}
+		return nil
+	}
+	app.Action = fn
+	return app.Run(append([]string{"rootlesskit"}, args...))
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "rootlesskit.toml")
+	assert.NilError(t, os.WriteFile(p, []byte(content), 0644))
+	return p
+}
+
+type testConfigValues struct {
+	Net         string
+	CopyUp      []string
+	Pidns       bool
+	OOMScoreAdj *int
+	IOWeight    uint64
+	StopTimeout time.Duration
+}
+
+func getTestConfigValues(clicontext *cli.Context) testConfigValues {
+	v := testConfigValues{
+		Net:         clicontext.String("net"),
+		CopyUp:      clicontext.StringSlice("copy-up"),
+		Pidns:       clicontext.Bool("pidns"),
+		IOWeight:    clicontext.Uint64("cgroup-io-weight"),
+		StopTimeout: clicontext.Duration("stop-timeout"),
+	}
+	if clicontext.IsSet("oom-score-adj") {
+		x := clicontext.Int("oom-score-adj")
+		v.OOMScoreAdj = &x
+	}
+	return v
+}
+
+func TestLoadConfig(t *testing.T) {
+	minus100 := -100
+	testCases := []struct {
+		name        string
+		config      string
+		args        []string
+		expected    testConfigValues
+		expectedErr string
+	}{
+		{
+			name:     "empty",
+			expected: testConfigValues{Net: "host"},
+		},
+		{
+			name: "values",
+			config: `net = "slirp4netns"
+copy-up = ["/etc", "/run"]
+pidns = true
+oom-score-adj = -100
+cgroup-io-weight = 100
+stop-timeout = "30s"
+`,
+			expected: testConfigValues{
+				Net:         "slirp4netns",
+				CopyUp:      []string{"/etc", "/run"},
+				Pidns:       true,
+				OOMScoreAdj: &minus100,
+				IOWeight:    100,
+				StopTimeout: 30 * time.Second,
+			},
+		},
+		{
+			name: "command line takes precedence",
+			config: `net = "slirp4netns"
+copy-up = ["/etc", "/run"]
+pidns = true
+`,
+			args: []string{"--net=pasta", "--copy-up=/var", "--pidns=false"},
+			expected: testConfigValues{
+				Net:    "pasta",
+				CopyUp: []string{"/var"},
+			},
+		},
+		{
+			name:        "array key with a string",
+			config:      `copy-up = "/etc"`,
+			expectedErr: `key "copy-up" must be an array`,
+		},
+		{
+			name:        "unknown key",
+			config:      `foo = "bar"`,
+			expectedErr: `unknown key "foo"`,
+		},
+		{
+			name:        "ignored key",
+			config:      `print-config = true`,
+			expectedErr: `unknown key "print-config"`,
+		},
+		{
+			name:        "float for an int flag",
+			config:      `oom-score-adj = 1.5`,
+			expectedErr: `key "oom-score-adj": invalid value "1.5"`,
+		},
+		{
+			name:        "integer for a duration flag",
+			config:      `stop-timeout = 30`,
+			expectedErr: `key "stop-timeout": invalid value "30"`,
+		},
+		{
+			name:        "invalid TOML",
+			config:      `net = `,
+			expectedErr: "failed to load the config file",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"--config=" + writeConfig(t, tc.config)}, tc.args...)
+			var values testConfigValues
+			err := runConfigApp(t, args, func(clicontext *cli.Context) error {
+				values = getTestConfigValues(clicontext)
+				return nil
+			})
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.expected, values)
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "defaults",
+			expected: "net = \"host\"\n",
+		},
+		{
+			name: "values",
+			args: []string{"--net=slirp4netns", "--copy-up=/etc", "--copy-up=/run", "--pidns",
+				"--oom-score-adj=0", "--cgroup-io-weight=100", "--stop-timeout=90s"},
+			expected: `cgroup-io-weight = 100
+copy-up = ["/etc", "/run"]
+net = "slirp4netns"
+oom-score-adj = 0
+pidns = true
+stop-timeout = "1m30s"
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var printed, reprinted bytes.Buffer
+			var values, reloaded testConfigValues
+			err := runConfigApp(t, tc.args, func(clicontext *cli.Context) error {
+				values = getTestConfigValues(clicontext)
+				return printConfig(&printed, clicontext)
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, tc.expected, printed.String())
+
+			// the printed config can be loaded again, without changing the values
+			args := []string{"--config=" + writeConfig(t, printed.String())}
+			err = runConfigApp(t, args, func(clicontext *cli.Context) error {
+				reloaded = getTestConfigValues(clicontext)
+				return printConfig(&reprinted, clicontext)
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, values, reloaded)
+			assert.Equal(t, printed.String(), reprinted.String())
+		})
+	}
+}
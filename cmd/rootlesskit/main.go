@@ -18,7 +18,10 @@ import (
 	"github.com/rootless-containers/rootlesskit/v3/cmd/rootlesskit/unshare"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/child"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/common"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup/overlayfs"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup/tmpfssymlink"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/hook"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/gvisortapvsock"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/lxcusernic"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/none"
@@ -26,10 +29,13 @@ import (
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/slirp4netns"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/vpnkit"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/parent"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/cgrouputil"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port/builtin"
 	gvisortapvsock_port "github.com/rootless-containers/rootlesskit/v3/pkg/port/gvisortapvsock"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port/portutil"
 	slirp4netns_port "github.com/rootless-containers/rootlesskit/v3/pkg/port/slirp4netns"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/seccomp"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/sigproxy/signal"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/systemd/activation"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/version"
 )
@@ -38,9 +44,12 @@ const (
 	pipeFDEnvKey              = "_ROOTLESSKIT_PIPEFD_UNDOCUMENTED"
 	childUseActivationEnvKey  = "_ROOTLESSKIT_SYSTEMD_ACTIVATION_CHILD_USE_UNDOCUMENTED"
 	runActivationHelperEnvKey = "_ROOTLESSKIT_SYSTEMD_ACTIVATION_RUN_HELPER_UNDOCUMENTED"
+	runExecHelperEnvKey       = "_ROOTLESSKIT_EXEC_HELPER_UNDOCUMENTED"
+	execHelperReportFDEnvKey  = "_ROOTLESSKIT_EXEC_HELPER_REPORT_FD_UNDOCUMENTED"
 	stateDirEnvKey            = "ROOTLESSKIT_STATE_DIR"   // documented
 	parentEUIDEnvKey          = "ROOTLESSKIT_PARENT_EUID" // documented
 	parentEGIDEnvKey          = "ROOTLESSKIT_PARENT_EGID" // documented
+	detachEnvKey              = "_ROOTLESSKIT_DETACH_UNDOCUMENTED"
 )
 
 func main() {
@@ -49,10 +58,13 @@ func main() {
 		return
 	}
 	iAmActivationHelper := checkActivationHelper()
+	iAmExecHelper := checkExecHelper()
 	iAmChild := os.Getenv(pipeFDEnvKey) != ""
 	id := "parent"
 	if iAmChild {
 		id = "child " // padded to len("parent")
+	} else if iAmExecHelper {
+		id = "exec_helper"
 	} else if iAmActivationHelper {
 		id = "activation_helper"
 	}
@@ -120,10 +132,22 @@ See https://rootlesscontaine.rs/getting-started/common/ .
 			Name:  "print-semver",
 			Usage: "print a version component as a decimal integer [major, minor, patch]",
 		}, CategoryMisc),
+		Categorize(&cli.StringFlag{
+			Name:  "config",
+			Usage: "load the flags from the TOML file. The keys correspond to the flag names. The flags specified on the command line take precedence",
+		}, CategoryMisc),
+		Categorize(&cli.BoolFlag{
+			Name:  "print-config",
+			Usage: "print the resolved flags as a TOML file, and exit",
+		}, CategoryMisc),
 		Categorize(&cli.StringFlag{
 			Name:  "state-dir",
 			Usage: "state directory",
 		}, CategoryState),
+		Categorize(&cli.StringFlag{
+			Name:  "result-file",
+			Usage: "write the JSON result (exit code, failed phase, error, etc.) to the file on exit. Should be outside the state directory",
+		}, CategoryState),
 		Categorize(&cli.StringFlag{
 			Name:  "net",
 			Usage: fmt.Sprintf("network driver [%s]", netDriversHelp),
@@ -187,11 +211,11 @@ See https://rootlesscontaine.rs/getting-started/common/ .
 		}, CategoryNetwork),
 		Categorize(&cli.StringSliceFlag{
 			Name:  "copy-up",
-			Usage: "mount a filesystem and copy-up the contents. e.g. \"--copy-up=/etc\" (typically required for non-host network)",
+			Usage: "mount a filesystem and copy-up the contents. e.g. \"--copy-up=/etc\" (typically required for non-host network). \"--copy-up=/etc:persist=DIR\" keeps the changes in DIR across the restarts (needs --copy-up-mode=overlayfs)",
 		}, CategoryMount),
 		Categorize(&cli.StringFlag{
 			Name:  "copy-up-mode",
-			Usage: "copy-up mode [tmpfs+symlink]",
+			Usage: "copy-up mode. \"overlayfs\" needs Linux 5.11 or later, or fuse-overlayfs [tmpfs+symlink, overlayfs]",
 			Value: "tmpfs+symlink",
 		}, CategoryMount),
 		Categorize(&cli.StringFlag{
@@ -221,10 +245,38 @@ See https://rootlesscontaine.rs/getting-started/common/ .
 			Name:  "utsns",
 			Usage: "create a UTS namespace",
 		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "hostname",
+			Usage: "set the hostname of the UTS namespace. Requires --utsns",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "domainname",
+			Usage: "set the domainname of the UTS namespace. Requires --utsns",
+		}, CategoryProcess),
 		Categorize(&cli.BoolFlag{
 			Name:  "ipcns",
 			Usage: "create an IPC namespace",
 		}, CategoryProcess),
+		Categorize(&cli.BoolFlag{
+			Name:  "timens",
+			Usage: "create a time namespace for the target command",
+		}, CategoryProcess),
+		Categorize(&cli.DurationFlag{
+			Name:  "monotonic-offset",
+			Usage: "CLOCK_MONOTONIC offset of the time namespace, can be negative. e.g. \"720h\". Requires --timens",
+		}, CategoryProcess),
+		Categorize(&cli.DurationFlag{
+			Name:  "boottime-offset",
+			Usage: "CLOCK_BOOTTIME offset of the time namespace, can be negative. e.g. \"720h\". Requires --timens",
+		}, CategoryProcess),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "rlimit",
+			Usage: "set a resource limit of the target command, e.g. \"nofile=1024:65536\". The hard limit cannot exceed the current hard limit. Can be specified multiple times",
+		}, CategoryProcess),
+		Categorize(&cli.IntFlag{
+			Name:  "oom-score-adj",
+			Usage: "set the OOM score adjustment of the target command [-1000, 1000]. Cannot be lower than the current value",
+		}, CategoryProcess),
 		Categorize(&cli.BoolFlag{
 			Name:  "detach-netns",
 			Usage: "detach network namespaces ",
@@ -243,11 +295,111 @@ See https://rootlesscontaine.rs/getting-started/common/ .
 			Name:  "evacuate-cgroup2",
 			Usage: "evacuate processes into the specified subgroup. Requires --pidns and --cgroupns",
 		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "cgroup-memory-max",
+			Usage: "set memory.max of the cgroup of the child, e.g. \"4G\". Requires --evacuate-cgroup2",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "cgroup-cpu-max",
+			Usage: "set cpu.max of the cgroup of the child in \"$MAX [$PERIOD]\" microseconds, e.g. \"200000 100000\" for 2 CPUs. Requires --evacuate-cgroup2",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "cgroup-pids-max",
+			Usage: "set pids.max of the cgroup of the child. Requires --evacuate-cgroup2",
+		}, CategoryProcess),
+		Categorize(&cli.Uint64Flag{
+			Name:  "cgroup-io-weight",
+			Usage: "set the default io.weight of the cgroup of the child [1, 10000]. Requires --evacuate-cgroup2",
+		}, CategoryProcess),
+		Categorize(&cli.BoolFlag{
+			Name:  "cgroup-freezer",
+			Usage: "create a separate cgroup for the child, so that the child can be frozen with \"rootlessctl pause\". Requires --evacuate-cgroup2",
+		}, CategoryProcess),
 		Categorize(&cli.StringFlag{
 			Name:  "subid-source",
 			Value: "auto",
-			Usage: "the source of the subids. \"dynamic\" executes /usr/bin/getsubids. \"static\" reads /etc/{subuid,subgid}. [auto,dynamic,static]",
+			Usage: "the source of the subids. \"dynamic\" executes /usr/bin/getsubids. \"static\" reads /etc/{subuid,subgid}. \"none\" maps only the current user, without newuidmap and newgidmap. \"auto\" falls back to \"none\" when the subids are unavailable [auto,dynamic,static,none]",
 		}, CategorySubID),
+		Categorize(&cli.StringFlag{
+			Name:  "subuid-file",
+			Usage: "read the subuids from the file instead of /etc/subuid. Implies --subid-source=static when --subid-source is auto",
+		}, CategorySubID),
+		Categorize(&cli.StringFlag{
+			Name:  "subgid-file",
+			Usage: "read the subgids from the file instead of /etc/subgid. Implies --subid-source=static when --subid-source is auto",
+		}, CategorySubID),
+		Categorize(&cli.StringFlag{
+			Name:  "userns",
+			Value: "default",
+			Usage: "the user namespace mode. \"default\" maps the current user to root. \"keep-id\" executes the target command as the current uid and gid, in a nested user namespace [default,keep-id]",
+		}, CategorySubID),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "uid-map",
+			Usage: "set an entry of uid_map as \"CONTAINER:HOST:SIZE\", instead of mapping all the subuids. The current uid has to be mapped to 0, the other host ids have to be subuids. Can be specified multiple times",
+		}, CategorySubID),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "gid-map",
+			Usage: "set an entry of gid_map as \"CONTAINER:HOST:SIZE\", instead of mapping all the subgids. The current gid has to be mapped to 0, the other host ids have to be subgids. Can be specified multiple times",
+		}, CategorySubID),
+		Categorize(&cli.IntFlag{
+			Name:  "subid-max",
+			Usage: "the maximum number of the subuids and the subgids to be mapped, respectively. 0 for unlimited",
+		}, CategorySubID),
+		Categorize(&cli.BoolFlag{
+			Name:  "detach",
+			Usage: "run in background. Use \"rootlessctl attach\" and \"rootlessctl logs\" for interacting with the child",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "log-file",
+			Usage: "log file for the stdout and the stderr of the detached child (default: \"<state-dir>/log\"). Requires --detach",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "sd-notify",
+			Usage: "sd_notify(3) mode. \"parent\" notifies READY=1 when the API is ready. \"forward\" also waits for READY=1 from the child via $NOTIFY_SOCKET. \"none\" propagates $NOTIFY_SOCKET to the child as-is [none, parent, forward]",
+			Value: "none",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "restart",
+			Usage: "restart the target command on a failure, without tearing down the namespaces and the network [no, on-failure[:N]]",
+			Value: "no",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "stop-signal",
+			Usage: "signal sent to the child on SIGTERM and \"rootlessctl shutdown\"",
+			Value: "SIGTERM",
+		}, CategoryProcess),
+		Categorize(&cli.DurationFlag{
+			Name:  "stop-timeout",
+			Usage: "send SIGKILL when the child does not exit within the duration after the stop signal. 0 for no timeout",
+		}, CategoryProcess),
+		Categorize(&cli.StringFlag{
+			Name:  "seccomp-profile",
+			Usage: "seccomp profile (OCI/Docker JSON) applied to the target command. \"builtin\" for the built-in profile. \"unconfined\" or empty for no filtering",
+		}, CategorySecurity),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "cap-drop",
+			Usage: "drop the capabilities of the target command in the user namespace. e.g. \"SYS_ADMIN\", \"ALL\"",
+		}, CategorySecurity),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "cap-add",
+			Usage: "add the capabilities of the target command in the user namespace, after applying --cap-drop. e.g. \"NET_ADMIN\"",
+		}, CategorySecurity),
+		Categorize(&cli.BoolFlag{
+			Name:  "no-new-privileges",
+			Usage: "set PR_SET_NO_NEW_PRIVS for the target command",
+		}, CategorySecurity),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "landlock-ro",
+			Usage: "allow the target command to read and execute the files under the path, with Landlock. Other paths are inaccessible unless allowed with --landlock-rw",
+		}, CategorySecurity),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "landlock-rw",
+			Usage: "allow the target command to read, write, and execute the files under the path, with Landlock. Other paths are inaccessible unless allowed with --landlock-ro",
+		}, CategorySecurity),
+		Categorize(&cli.StringSliceFlag{
+			Name:  "hook",
+			Usage: "run an executable at the phase, with the JSON state on stdin. e.g. \"--hook=network:/usr/local/bin/foo\" [idmap, userns, network, ports, exit]",
+		}, CategoryHook),
 	}
 	app.CustomAppHelpTemplate = `NAME:
    {{.Name}}{{if .Usage}} - {{.Usage}}{{end}}
@@ -267,6 +419,11 @@ OPTIONS:
 		Categorize(cli.VersionFlag, CategoryMisc)))
 
 	app.Before = func(context *cli.Context) error {
+		if configPath := context.String("config"); configPath != "" {
+			if err := loadConfig(context, configPath); err != nil {
+				return err
+			}
+		}
 		if debug {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
@@ -296,9 +453,19 @@ OPTIONS:
 			}
 			return nil
 		}
+		if clicontext.Bool("print-config") {
+			return printConfig(clicontext.App.Writer, clicontext)
+		}
 		if clicontext.NArg() < 1 {
 			return errors.New("no command specified")
 		}
+		if iAmExecHelper {
+			execHelperOpt, err := createExecHelperOpt(clicontext, iAmActivationHelper)
+			if err != nil {
+				return err
+			}
+			return child.ExecHelper(execHelperOpt)
+		}
 		if iAmActivationHelper {
 			activationOpt, err := createActivationOpts(clicontext)
 			if err != nil {
@@ -373,7 +540,26 @@ func createParentOpt(clicontext *cli.Context) (parent.Opt, error) {
 		ParentEGIDEnvKey:         parentEGIDEnvKey,
 		Propagation:              clicontext.String("propagation"),
 		EvacuateCgroup2:          clicontext.String("evacuate-cgroup2"),
+		CgroupFreezer:            clicontext.Bool("cgroup-freezer"),
 		SubidSource:              parent.SubidSource(clicontext.String("subid-source")),
+		SubuidFile:               clicontext.String("subuid-file"),
+		SubgidFile:               clicontext.String("subgid-file"),
+		MaxSubIDs:                clicontext.Int("subid-max"),
+		Detach:                   clicontext.Bool("detach"),
+		DetachEnvKey:             detachEnvKey,
+		SdNotify:                 parent.SdNotify(clicontext.String("sd-notify")),
+		StopTimeout:              clicontext.Duration("stop-timeout"),
+		CreateTimeNS:             clicontext.Bool("timens"),
+		MonotonicOffset:          clicontext.Duration("monotonic-offset"),
+		BoottimeOffset:           clicontext.Duration("boottime-offset"),
+		Landlock:                 len(clicontext.StringSlice("landlock-ro")) != 0 || len(clicontext.StringSlice("landlock-rw")) != 0,
+	}
+	opt.StopSignal, err = signal.ParseSignal(clicontext.String("stop-signal"))
+	if err != nil {
+		return opt, err
+	}
+	if opt.StopTimeout < 0 {
+		return opt, errors.New("stop-timeout must not be negative")
 	}
 	if opt.EvacuateCgroup2 != "" {
 		if !opt.CreateCgroupNS {
@@ -383,6 +569,123 @@ func createParentOpt(clicontext *cli.Context) (parent.Opt, error) {
 			return opt, errors.New("evacuate-cgroup2 requires --pidns")
 		}
 	}
+	if opt.MaxSubIDs < 0 {
+		return opt, errors.New("subid-max must not be negative")
+	}
+	for _, s := range clicontext.StringSlice("uid-map") {
+		m, err := parent.ParseIDMap(s)
+		if err != nil {
+			return opt, err
+		}
+		opt.UIDMap = append(opt.UIDMap, m)
+	}
+	for _, s := range clicontext.StringSlice("gid-map") {
+		m, err := parent.ParseIDMap(s)
+		if err != nil {
+			return opt, err
+		}
+		opt.GIDMap = append(opt.GIDMap, m)
+	}
+	if opt.SubidSource == parent.SubidSourceNone && (opt.UIDMap != nil || opt.GIDMap != nil) {
+		return opt, errors.New("subid-source=none cannot be used with --uid-map and --gid-map")
+	}
+	if opt.SubuidFile != "" || opt.SubgidFile != "" {
+		switch opt.SubidSource {
+		case parent.SubidSourceAuto, parent.SubidSourceStatic:
+		default:
+			return opt, fmt.Errorf("--subuid-file and --subgid-file cannot be used with subid-source=%s", opt.SubidSource)
+		}
+	}
+	switch s := clicontext.String("userns"); s {
+	case "default":
+	case "keep-id":
+		if opt.UIDMap != nil || opt.GIDMap != nil {
+			return opt, errors.New("userns=keep-id cannot be used with --uid-map and --gid-map")
+		}
+	default:
+		return opt, fmt.Errorf("unknown userns mode: %s", s)
+	}
+	_, opt.CopyUpPersistDirs, err = copyUpDirs(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.CgroupLimits, err = cgrouputil.NewLimits(clicontext.String("cgroup-memory-max"), clicontext.String("cgroup-cpu-max"),
+		clicontext.String("cgroup-pids-max"), clicontext.Uint64("cgroup-io-weight"))
+	if err != nil {
+		return opt, err
+	}
+	if opt.CgroupLimits != nil && opt.EvacuateCgroup2 == "" {
+		return opt, errors.New("cgroup limits (--cgroup-*-max, --cgroup-io-weight) require --evacuate-cgroup2")
+	}
+	if opt.CgroupFreezer && opt.EvacuateCgroup2 == "" {
+		return opt, errors.New("cgroup-freezer requires --evacuate-cgroup2")
+	}
+	for _, name := range []string{"hostname", "domainname"} {
+		if s := clicontext.String(name); s != "" {
+			if !opt.CreateUTSNS {
+				return opt, fmt.Errorf("%s requires --utsns", name)
+			}
+			if err := child.ValidateHostname(s); err != nil {
+				return opt, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	if !opt.CreateTimeNS {
+		if opt.MonotonicOffset != 0 {
+			return opt, errors.New("monotonic-offset requires --timens")
+		}
+		if opt.BoottimeOffset != 0 {
+			return opt, errors.New("boottime-offset requires --timens")
+		}
+	}
+	// The restart policy is used by the child, but validated here for failing early
+	if _, err := child.ParseRestartPolicy(clicontext.String("restart")); err != nil {
+		return opt, err
+	}
+	// The resource limits are used by the child, but validated here for failing early
+	rlimits, _, err := resourceLimits(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	for _, r := range rlimits {
+		if err := child.ValidateRlimit(r); err != nil {
+			return opt, err
+		}
+	}
+	// The capabilities and the seccomp profile are used by the child, but validated here for failing early
+	caps, err := child.TargetCaps(clicontext.StringSlice("cap-drop"), clicontext.StringSlice("cap-add"))
+	if err != nil {
+		return opt, err
+	}
+	if profile, err := loadSeccompProfile(clicontext); err != nil {
+		return opt, err
+	} else if profile != nil {
+		if caps == nil {
+			caps, _ = child.TargetCaps(nil, []string{"ALL"})
+		}
+		if _, err := seccomp.Compile(profile, caps); err != nil {
+			return opt, fmt.Errorf("failed to compile the seccomp profile: %w", err)
+		}
+	}
+	if s := clicontext.String("log-file"); s != "" {
+		if !opt.Detach {
+			return opt, errors.New("log-file requires --detach")
+		}
+		opt.LogFile, err = filepath.Abs(s)
+		if err != nil {
+			return opt, err
+		}
+	}
+	if s := clicontext.String("result-file"); s != "" {
+		opt.ResultFile, err = filepath.Abs(s)
+		if err != nil {
+			return opt, err
+		}
+	}
+	opt.Hooks, err = parseHooks(clicontext)
+	if err != nil {
+		return opt, err
+	}
 	opt.StateDir = clicontext.String("state-dir")
 	if opt.StateDir == "" {
 		opt.StateDir, err = os.MkdirTemp("", "rootlesskit")
@@ -641,6 +944,13 @@ func createParentOpt(clicontext *cli.Context) (parent.Opt, error) {
 	default:
 		return opt, fmt.Errorf("unknown port driver: %s", s)
 	}
+	if opt.CgroupFreezer {
+		switch s := clicontext.String("port-driver"); s {
+		case "none", "builtin":
+		default:
+			return opt, fmt.Errorf("port driver %q cannot be used with --cgroup-freezer, as it cannot refuse new connections while the child is frozen", s)
+		}
+	}
 	for _, s := range clicontext.StringSlice("publish") {
 		spec, err := portutil.ParsePortSpec(s)
 		if err != nil {
@@ -667,12 +977,26 @@ func (w *logrusDebugWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+func parseHooks(clicontext *cli.Context) ([]hook.Hook, error) {
+	var hooks []hook.Hook
+	for _, s := range clicontext.StringSlice("hook") {
+		h, err := hook.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
 func createChildOpt(clicontext *cli.Context) (child.Opt, error) {
 	pidns := clicontext.Bool("pidns")
 	detachNetNS := clicontext.Bool("detach-netns")
 	opt := child.Opt{
 		PipeFDEnvKey:              pipeFDEnvKey,
 		RunActivationHelperEnvKey: runActivationHelperEnvKey,
+		RunExecHelperEnvKey:       runExecHelperEnvKey,
+		ExecHelperReportFDEnvKey:  execHelperReportFDEnvKey,
 		ChildUseActivationEnvKey:  childUseActivationEnvKey,
 		StateDirEnvKey:            stateDirEnvKey,
 		TargetCmd:                 clicontext.Args().Slice(),
@@ -680,6 +1004,42 @@ func createChildOpt(clicontext *cli.Context) (child.Opt, error) {
 		DetachNetNS:               detachNetNS,
 		Propagation:               clicontext.String("propagation"),
 		EvacuateCgroup2:           clicontext.String("evacuate-cgroup2") != "",
+		CreateTimeNS:              clicontext.Bool("timens"),
+		MonotonicOffset:           clicontext.Duration("monotonic-offset"),
+		BoottimeOffset:            clicontext.Duration("boottime-offset"),
+		Hostname:                  clicontext.String("hostname"),
+		Domainname:                clicontext.String("domainname"),
+	}
+	var err error
+	opt.Hooks, err = parseHooks(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.Restart, err = child.ParseRestartPolicy(clicontext.String("restart"))
+	if err != nil {
+		return opt, err
+	}
+	opt.SeccompProfile, err = loadSeccompProfile(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.Caps, err = child.TargetCaps(clicontext.StringSlice("cap-drop"), clicontext.StringSlice("cap-add"))
+	if err != nil {
+		return opt, err
+	}
+	opt.NoNewPrivileges = clicontext.Bool("no-new-privileges")
+	opt.LandlockRO, opt.LandlockRW, err = landlockPaths(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.Rlimits, opt.OOMScoreAdj, err = resourceLimits(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.KeepID = clicontext.String("userns") == "keep-id"
+	opt.StopSignal, err = signal.ParseSignal(clicontext.String("stop-signal"))
+	if err != nil {
+		return opt, err
 	}
 	switch reaperStr := clicontext.String("reaper"); reaperStr {
 	case "auto":
@@ -712,10 +1072,18 @@ func createChildOpt(clicontext *cli.Context) (child.Opt, error) {
 	default:
 		return opt, fmt.Errorf("unknown network mode: %s", s)
 	}
-	opt.CopyUpDirs = clicontext.StringSlice("copy-up")
+	var persistDirs map[string]string
+	opt.CopyUpDirs, persistDirs, err = copyUpDirs(clicontext)
+	if err != nil {
+		return opt, err
+	}
 	switch s := clicontext.String("copy-up-mode"); s {
-	case "tmpfs+symlink":
-		opt.CopyUpDriver = tmpfssymlink.NewChildDriver()
+	case "tmpfs+symlink", "overlayfs":
+		if s == "overlayfs" {
+			opt.CopyUpDriver = overlayfs.NewChildDriver(persistDirs)
+		} else {
+			opt.CopyUpDriver = tmpfssymlink.NewChildDriver()
+		}
 		if len(opt.CopyUpDirs) != 0 && (opt.Propagation == "rshared" || opt.Propagation == "shared") {
 			return opt, fmt.Errorf("propagation %s does not support copy-up driver %s", opt.Propagation, s)
 		}
@@ -775,6 +1143,127 @@ func checkActivationHelper() bool {
 	return activationHelperValue
 }
 
+func checkExecHelper() bool {
+	v, _ := strconv.ParseBool(os.Getenv(runExecHelperEnvKey))
+	return v
+}
+
+// createExecHelperOpt creates the option for the exec helper.
+// The exec helper is executed with the same flags as the child.
+func createExecHelperOpt(clicontext *cli.Context, activationHelper bool) (child.ExecHelperOpt, error) {
+	opt := child.ExecHelperOpt{
+		RunExecHelperEnvKey: runExecHelperEnvKey,
+		ReportFDEnvKey:      execHelperReportFDEnvKey,
+		TargetCmd:           clicontext.Args().Slice(),
+	}
+	var err error
+	opt.SeccompProfile, err = loadSeccompProfile(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.Caps, err = child.TargetCaps(clicontext.StringSlice("cap-drop"), clicontext.StringSlice("cap-add"))
+	if err != nil {
+		return opt, err
+	}
+	opt.NoNewPrivileges = clicontext.Bool("no-new-privileges")
+	opt.LandlockRO, opt.LandlockRW, err = landlockPaths(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.Rlimits, opt.OOMScoreAdj, err = resourceLimits(clicontext)
+	if err != nil {
+		return opt, err
+	}
+	opt.KeepID = clicontext.String("userns") == "keep-id"
+	if activationHelper {
+		activationOpt, err := createActivationOpts(clicontext)
+		if err != nil {
+			return opt, err
+		}
+		opt.Activation = &activationOpt
+	}
+	return opt, nil
+}
+
+// copyUpDirs parses --copy-up, and returns the directories and the persistent directories keyed by the directories.
+func copyUpDirs(clicontext *cli.Context) ([]string, map[string]string, error) {
+	var dirs []string
+	persistDirs := make(map[string]string)
+	for _, s := range clicontext.StringSlice("copy-up") {
+		d, err := copyup.ParseDir(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		dirs = append(dirs, d.Path)
+		if d.PersistDir != "" {
+			persistDirs[d.Path] = d.PersistDir
+		}
+	}
+	if len(persistDirs) == 0 {
+		return dirs, nil, nil
+	}
+	if mode := clicontext.String("copy-up-mode"); mode != "overlayfs" {
+		return nil, nil, fmt.Errorf("copy-up mode %q does not support persist, use --copy-up-mode=overlayfs", mode)
+	}
+	for _, persistDir := range persistDirs {
+		for _, dir := range dirs {
+			// the persistent directory would be hidden by the overlay of the copy-up directory
+			if persistDir == dir || strings.HasPrefix(persistDir, dir+"/") || dir == "/" {
+				return nil, nil, fmt.Errorf("the persistent directory %q must not be under the copy-up directory %q", persistDir, dir)
+			}
+		}
+	}
+	return dirs, persistDirs, nil
+}
+
+// landlockPaths returns the absolute paths of --landlock-ro and --landlock-rw.
+func landlockPaths(clicontext *cli.Context) (ro, rw []string, err error) {
+	for _, s := range clicontext.StringSlice("landlock-ro") {
+		p, err := filepath.Abs(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		ro = append(ro, p)
+	}
+	for _, s := range clicontext.StringSlice("landlock-rw") {
+		p, err := filepath.Abs(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		rw = append(rw, p)
+	}
+	return ro, rw, nil
+}
+
+// resourceLimits returns the rlimits and the OOM score adjustment of the target command.
+// oomScoreAdj is nil when --oom-score-adj is not set.
+func resourceLimits(clicontext *cli.Context) (rlimits []child.Rlimit, oomScoreAdj *int, err error) {
+	for _, s := range clicontext.StringSlice("rlimit") {
+		r, err := child.ParseRlimit(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		rlimits = append(rlimits, r)
+	}
+	if clicontext.IsSet("oom-score-adj") {
+		v := clicontext.Int("oom-score-adj")
+		if err := child.ValidateOOMScoreAdj(v); err != nil {
+			return nil, nil, err
+		}
+		oomScoreAdj = &v
+	}
+	return rlimits, oomScoreAdj, nil
+}
+
+// loadSeccompProfile returns nil when --seccomp-profile is not set.
+func loadSeccompProfile(clicontext *cli.Context) (*seccomp.Profile, error) {
+	s := clicontext.String("seccomp-profile")
+	if s == "" || s == "unconfined" {
+		return nil, nil
+	}
+	return seccomp.LoadProfile(s)
+}
+
 func createActivationOpts(clicontext *cli.Context) (activation.Opt, error) {
 	opt := activation.Opt{
 		RunActivationHelperEnvKey: runActivationHelperEnvKey,
@@ -0,0 +1,28 @@
+#!/bin/bash
+source $(realpath $(dirname $0))/common.inc.sh
+
+# Simulate the tmpfs on /run/user/$UID mounted by systemd-logind
+run_user=/run/user/$(id -u)
+if ! findmnt -n $run_user >/dev/null; then
+	sudo mkdir -p $run_user
+	sudo mount -t tmpfs -o mode=700,uid=$(id -u),gid=$(id -g) none $run_user
+fi
+
+function test_copy_up() {
+	mode=$1
+	INFO "Testing --copy-up-mode=$mode"
+	state=$run_user/rootlesskit-integration-copy-up
+	rm -rf $state
+	# The state directory under the submount of /run has to remain accessible in the child,
+	# as resolv.conf is written there
+	$ROOTLESSKIT --state-dir=$state --net=slirp4netns --copy-up-mode=$mode --copy-up=/etc --copy-up=/run -- sh -exc '
+		test -S $ROOTLESSKIT_STATE_DIR/api.sock
+		grep nameserver /etc/resolv.conf
+		touch /etc/.integration-copy-up /run/.integration-copy-up
+	'
+	test ! -e /etc/.integration-copy-up
+	test ! -e /run/.integration-copy-up
+}
+
+test_copy_up tmpfs+symlink
+test_copy_up overlayfs
@@ -0,0 +1,42 @@
+#!/bin/bash
+source $(realpath $(dirname $0))/common.inc.sh
+
+INFO "Testing --detach"
+tmp=$(mktemp -d)
+state_dir=${tmp}/state
+set -x
+$ROOTLESSKIT --state-dir=${state_dir} --detach sh -c 'echo hello; while read l; do echo "got $l"; done'
+# the API should be ready when the foreground process exits
+if [ "$(rootlessctl --socket=${state_dir}/api.sock exec -- id -u)" != "0" ]; then
+	ERROR "expected UID 0"
+	exit 1
+fi
+if ! rootlessctl --socket=${state_dir}/api.sock logs | grep -q hello; then
+	ERROR "expected \"hello\" in the log"
+	exit 1
+fi
+if ! (echo foo | timeout 3 rootlessctl --socket=${state_dir}/api.sock attach || true) | grep -q "got foo"; then
+	ERROR "stdin is not propagated"
+	exit 1
+fi
+if ! grep -q "got foo" ${state_dir}/log; then
+	ERROR "expected \"got foo\" in ${state_dir}/log"
+	exit 1
+fi
+kill $(cat ${state_dir}/child_pid)
+set +x
+rm -rf $tmp
+
+INFO "Testing --detach --log-file"
+tmp=$(mktemp -d)
+state_dir=${tmp}/state
+set -x
+$ROOTLESSKIT --state-dir=${state_dir} --detach --log-file=${tmp}/log sh -c 'echo hello; sleep infinity'
+sleep 1
+if ! grep -q hello ${tmp}/log; then
+	ERROR "expected \"hello\" in ${tmp}/log"
+	exit 1
+fi
+kill $(cat ${state_dir}/child_pid)
+set +x
+rm -rf $tmp
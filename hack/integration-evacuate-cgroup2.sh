@@ -10,3 +10,14 @@ if [ "$group" != "/evac" ]; then
   ERROR "expected group \"/evac\", got \"${group}\"."
   exit 1
 fi
+
+# The limits cover the groups created by the target command, as the limited group is the root of the cgroup namespace.
+# Requires the unified mode, with the pids controller delegated.
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+  out="$(systemd-run --user -p Delegate=yes -t -q -- $ROOTLESSKIT --cgroupns --pidns --evacuate-cgroup2=evac --cgroup-pids-max=100 sh -ec \
+    'mkdir /sys/fs/cgroup/sibling; echo $$ >/sys/fs/cgroup/sibling/cgroup.procs; echo "$(grep -oP "0::\K.*" /proc/self/cgroup) $(cat /sys/fs/cgroup/pids.max)"' | sed 's/[^[:print:]]//g')"
+  if [ "$out" != "/sibling 100" ]; then
+    ERROR "expected the sibling group to be limited by pids.max=100, got \"${out}\"."
+    exit 1
+  fi
+fi
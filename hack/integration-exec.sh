@@ -0,0 +1,42 @@
+#!/bin/bash
+source $(realpath $(dirname $0))/common.inc.sh
+
+function test_exec() {
+	args="$@"
+	INFO "Testing rootlessctl exec for args=${args}"
+	tmp=$(mktemp -d)
+	state_dir=${tmp}/state
+	$ROOTLESSKIT --state-dir=${state_dir} $args sleep infinity &
+	pid=$!
+	sleep 2
+
+	set -x
+	if [ "$(rootlessctl --socket=${state_dir}/api.sock exec -- id -u)" != "0" ]; then
+		ERROR "expected UID 0"
+		exit 1
+	fi
+	if [ "$(readlink /proc/$(cat ${state_dir}/child_pid)/ns/net)" != "$(rootlessctl --socket=${state_dir}/api.sock exec -- readlink /proc/self/ns/net)" ]; then
+		ERROR "netns mismatch"
+		exit 1
+	fi
+	if [ "$(echo hello | rootlessctl --socket=${state_dir}/api.sock exec -- cat)" != "hello" ]; then
+		ERROR "stdin is not propagated"
+		exit 1
+	fi
+	set +e
+	rootlessctl --socket=${state_dir}/api.sock exec -- sh -c "exit 42"
+	code=$?
+	set -e
+	if [ $code != 42 ]; then
+		ERROR "expected code 42, got $code"
+		exit 1
+	fi
+	set +x
+
+	kill $pid
+	wait $pid || true
+	rm -rf $tmp
+}
+
+test_exec --net=slirp4netns
+test_exec --net=slirp4netns --pidns --utsns --ipcns --cgroupns
@@ -1,11 +1,14 @@
 package api
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 const (
 	// Version of the REST API, not implementation version.
 	// See openapi.yaml for the definition.
-	Version = "1.1.1"
+	Version = "1.2.0"
 )
 
 // Info is the structure returned by `GET /info`
@@ -16,6 +19,44 @@ type Info struct {
 	ChildPID      int                `json:"childPID"`
 	NetworkDriver *NetworkDriverInfo `json:"networkDriver,omitempty"`
 	PortDriver    *PortDriverInfo    `json:"portDriver,omitempty"`
+	// TargetRestarts is the number of the restarts of the target command with the restart policy.
+	TargetRestarts int `json:"targetRestarts"` // since API v1.2.0
+	// TimeNamespace is set only when the time namespace was created for the target command.
+	TimeNamespace *TimeNamespaceInfo `json:"timeNamespace,omitempty"` // since API v1.2.0
+	// Landlock is set only when Landlock was requested for the target command,
+	// after the child reported the enforced ABI version.
+	Landlock *LandlockInfo `json:"landlock,omitempty"` // since API v1.2.0
+	// IDMap is the effective uid_map and gid_map of the child.
+	IDMap *IDMapInfo `json:"idMap,omitempty"` // since API v1.2.0
+}
+
+// IDMapInfo in Info (since API v1.2.0)
+type IDMapInfo struct {
+	UIDMap []IDMap `json:"uidMap"`
+	GIDMap []IDMap `json:"gidMap"`
+	// SetgroupsDenied is true when "deny" was written to /proc/PID/setgroups,
+	// i.e., when the subids are not available (`--subid-source=none`).
+	SetgroupsDenied bool `json:"setgroupsDenied,omitempty"`
+}
+
+// IDMap is an entry of uid_map or gid_map
+type IDMap struct {
+	ContainerID int `json:"containerID"`
+	HostID      int `json:"hostID"`
+	Size        int `json:"size"`
+}
+
+// LandlockInfo in Info (since API v1.2.0)
+type LandlockInfo struct {
+	// ABI is the Landlock ABI version enforced for the target command.
+	// ABI is 0 when Landlock is not supported by the kernel.
+	ABI int `json:"abi"`
+}
+
+// TimeNamespaceInfo in Info (since API v1.2.0)
+type TimeNamespaceInfo struct {
+	MonotonicOffset time.Duration `json:"monotonicOffset"` // nanoseconds
+	BoottimeOffset  time.Duration `json:"boottimeOffset"`  // nanoseconds
 }
 
 // NetworkDriverInfo in Info
@@ -32,3 +73,53 @@ type PortDriverInfo struct {
 	Protos                  []string `json:"protos"`
 	DisallowLoopbackChildIP bool     `json:"disallowLoopbackChildIP,omitempty"` // since API v1.1.1
 }
+
+// ExecRequest is the structure posted to `POST /exec` (since API v1.2.0)
+type ExecRequest struct {
+	Args       []string `json:"args"`
+	Env        []string `json:"env,omitempty"` // appended to the environment of the parent
+	WorkingDir string   `json:"workingDir,omitempty"`
+	TTY        bool     `json:"tty,omitempty"`
+	Width      uint16   `json:"width,omitempty"`  // only for TTY
+	Height     uint16   `json:"height,omitempty"` // only for TTY
+}
+
+// Cgroup is the structure returned by `GET /cgroup` (since API v1.2.0)
+type Cgroup struct {
+	// Path is the cgroup2 directory of the child, e.g. "/sys/fs/cgroup/user.slice/.../rootlesskit_evacuation"
+	Path          string   `json:"path"`
+	Controllers   []string `json:"controllers,omitempty"`
+	MemoryMax     string   `json:"memoryMax,omitempty"` // "memory.max", bytes or "max"
+	MemoryCurrent uint64   `json:"memoryCurrent,omitempty"`
+	CPUMax        string   `json:"cpuMax,omitempty"` // "cpu.max", "$MAX $PERIOD"
+	CPUUsageUsec  uint64   `json:"cpuUsageUsec,omitempty"`
+	PidsMax       string   `json:"pidsMax,omitempty"` // "pids.max", number or "max"
+	PidsCurrent   uint64   `json:"pidsCurrent,omitempty"`
+	IOWeight      uint64   `json:"ioWeight,omitempty"` // the default weight in "io.weight"
+	// Frozen is true when the child was frozen with `POST /freeze`
+	Frozen bool `json:"frozen,omitempty"`
+}
+
+// Sysctl is the structure returned by `GET /sysctls/{key}` and `PUT /sysctls/{key}`,
+// and posted to `PUT /sysctls/{key}` (since API v1.2.0)
+type Sysctl struct {
+	Key   string `json:"key,omitempty"` // ignored for PUT requests
+	Value string `json:"value"`
+}
+
+// CopyUpChange is an entry of the array returned by `GET /copy-up/diff` (since API v1.2.0)
+type CopyUpChange struct {
+	Path string           `json:"path"` // e.g. "/etc/hosts"
+	Kind CopyUpChangeKind `json:"kind"`
+}
+
+type CopyUpChangeKind string
+
+const (
+	CopyUpChangeAdded   = CopyUpChangeKind("added")
+	CopyUpChangeChanged = CopyUpChangeKind("changed")
+	CopyUpChangeDeleted = CopyUpChangeKind("deleted")
+	// CopyUpChangeUnreadable is reported for the path that cannot be read by the parent.
+	// The changes under the path are not reported.
+	CopyUpChangeUnreadable = CopyUpChangeKind("unreadable")
+)
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+func (c *client) Cgroup(ctx context.Context) (*api.Cgroup, error) {
+	u := fmt.Sprintf("http://%s/%s/cgroup", c.dummyHost, c.version)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := httputil.Successful(resp); err != nil {
+		return nil, err
+	}
+	var cg api.Cgroup
+	if err := json.NewDecoder(resp.Body).Decode(&cg); err != nil {
+		return nil, err
+	}
+	return &cg, nil
+}
+
+func (c *client) Freeze(ctx context.Context) error {
+	return c.freeze(ctx, "freeze")
+}
+
+func (c *client) Thaw(ctx context.Context) error {
+	return c.freeze(ctx, "thaw")
+}
+
+func (c *client) freeze(ctx context.Context, action string) error {
+	u := fmt.Sprintf("http://%s/%s/%s", c.dummyHost, c.version, action)
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return httputil.Successful(resp)
+}
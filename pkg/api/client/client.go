@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
 )
@@ -16,6 +19,34 @@ type Client interface {
 	HTTPClient() *http.Client
 	PortManager() port.Manager
 	Info(context.Context) (*api.Info, error)
+	// Exec executes a command in the namespaces, and returns the exit code.
+	// stdin can be nil.
+	Exec(ctx context.Context, req *api.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+	// Logs writes the log of the detached child to w.
+	// When follow is true, Logs blocks until the child exits or ctx is cancelled.
+	Logs(ctx context.Context, follow bool, w io.Writer) error
+	// Attach attaches stdin, stdout, and stderr to the detached child.
+	// Attach blocks until the child exits or ctx is cancelled.
+	// stdin can be nil.
+	Attach(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error
+	// Events calls fn for each event, until the parent shuts down, ctx is cancelled, or fn returns an error.
+	// The recent past events are also passed to fn if since is non-zero.
+	Events(ctx context.Context, since time.Time, fn func(*events.Event) error) error
+	// Shutdown initiates the graceful shutdown of the child.
+	// Shutdown returns without waiting for the child to exit.
+	Shutdown(ctx context.Context) error
+	// Sysctl gets the sysctl in the namespaces of the child.
+	Sysctl(ctx context.Context, key string) (*api.Sysctl, error)
+	// SetSysctl sets the sysctl in the namespaces of the child.
+	SetSysctl(ctx context.Context, key, value string) (*api.Sysctl, error)
+	// Cgroup returns the limits and usage of the cgroup of the child.
+	Cgroup(ctx context.Context) (*api.Cgroup, error)
+	// Freeze freezes the processes of the child, and waits for the cgroup to be frozen.
+	Freeze(ctx context.Context) error
+	// Thaw thaws the processes of the child, and waits for the cgroup to be thawed.
+	Thaw(ctx context.Context) error
+	// CopyUpDiff returns the changes in the persistent copy-up layers.
+	CopyUpDiff(ctx context.Context) ([]api.CopyUpChange, error)
 }
 
 // New creates a client.
@@ -77,6 +108,21 @@ func (c *client) Info(ctx context.Context) (*api.Info, error) {
 	return &info, nil
 }
 
+func (c *client) Shutdown(ctx context.Context) error {
+	u := fmt.Sprintf("http://%s/%s/shutdown", c.dummyHost, c.version)
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return httputil.Successful(resp)
+}
+
 type portManager struct {
 	*client
 }
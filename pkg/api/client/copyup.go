@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+func (c *client) CopyUpDiff(ctx context.Context) ([]api.CopyUpChange, error) {
+	u := fmt.Sprintf("http://%s/%s/copy-up/diff", c.dummyHost, c.version)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := httputil.Successful(resp); err != nil {
+		return nil, err
+	}
+	var changes []api.CopyUpChange
+	if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+func (c *client) Events(ctx context.Context, since time.Time, fn func(*events.Event) error) error {
+	u := fmt.Sprintf("http://%s/%s/events", c.dummyHost, c.version)
+	if !since.IsZero() {
+		u += "?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := httputil.Successful(resp); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev events.Event
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(&ev); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/stdcopy"
+)
+
+func (c *client) Exec(ctx context.Context, req *api.ExecRequest, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	m, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	u := fmt.Sprintf("http://%s/%s/exec", c.dummyHost, c.version)
+	conn, err := c.upgrade(ctx, u, m)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if stdin != nil {
+		go func() {
+			mux := stdcopy.NewMuxer(conn)
+			if _, err := io.Copy(mux.Writer(stdcopy.Stdin), stdin); err != nil {
+				logrus.WithError(err).Debug("exec: stdin")
+			}
+			_ = mux.CloseStdin()
+		}()
+	} else {
+		if err := stdcopy.NewMuxer(conn).CloseStdin(); err != nil {
+			return 0, err
+		}
+	}
+	return stdcopy.Demux(conn, stdout, stderr)
+}
+
+// upgrade sends a POST request with "Upgrade: tcp" header, and returns the hijacked connection.
+func (c *client) upgrade(ctx context.Context, u string, body []byte) (io.ReadWriteCloser, error) {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		if err := httputil.Successful(resp); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("expected HTTP status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%T is not io.ReadWriteCloser", resp.Body)
+	}
+	return conn, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/stdcopy"
+)
+
+func (c *client) Logs(ctx context.Context, follow bool, w io.Writer) error {
+	u := fmt.Sprintf("http://%s/%s/logs?follow=%s", c.dummyHost, c.version, strconv.FormatBool(follow))
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := httputil.Successful(resp); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *client) Attach(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+	u := fmt.Sprintf("http://%s/%s/attach", c.dummyHost, c.version)
+	conn, err := c.upgrade(ctx, u, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if stdin != nil {
+		go func() {
+			mux := stdcopy.NewMuxer(conn)
+			if _, err := io.Copy(mux.Writer(stdcopy.Stdin), stdin); err != nil {
+				logrus.WithError(err).Debug("attach: stdin")
+			}
+			_ = mux.CloseStdin()
+		}()
+	} else {
+		if err := stdcopy.NewMuxer(conn).CloseStdin(); err != nil {
+			return err
+		}
+	}
+	// The stream does not contain the exit code frame, as the exit code of the child is unknown to the server
+	// when the stream is closed.
+	if _, err = stdcopy.Demux(conn, stdout, stderr); err != nil && !errors.Is(err, stdcopy.ErrNoExitCode) {
+		return err
+	}
+	return nil
+}
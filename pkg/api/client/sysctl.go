@@ -0,0 +1,51 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+func (c *client) Sysctl(ctx context.Context, key string) (*api.Sysctl, error) {
+	return c.sysctl(ctx, "GET", key, nil)
+}
+
+func (c *client) SetSysctl(ctx context.Context, key, value string) (*api.Sysctl, error) {
+	m, err := json.Marshal(&api.Sysctl{Value: value})
+	if err != nil {
+		return nil, err
+	}
+	return c.sysctl(ctx, "PUT", key, bytes.NewReader(m))
+}
+
+func (c *client) sysctl(ctx context.Context, method, key string, body io.Reader) (*api.Sysctl, error) {
+	u := fmt.Sprintf("http://%s/%s/sysctls/%s", c.dummyHost, c.version, url.PathEscape(key))
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := httputil.Successful(resp); err != nil {
+		return nil, err
+	}
+	var sysctl api.Sysctl
+	if err := json.NewDecoder(resp.Body).Decode(&sysctl); err != nil {
+		return nil, err
+	}
+	return &sysctl, nil
+}
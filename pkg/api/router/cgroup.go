@@ -0,0 +1,52 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+// GetCgroup is handler for GET /v{N}/cgroup
+func (b *Backend) GetCgroup(w http.ResponseWriter, r *http.Request) {
+	if b.Cgroup == nil {
+		httputil.WriteError(w, r, errors.New("cgroup is not supported"), http.StatusNotImplemented)
+		return
+	}
+	cg, err := b.Cgroup()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	m, err := json.Marshal(cg)
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(m)
+}
+
+// PostFreeze is handler for POST /v{N}/freeze
+func (b *Backend) PostFreeze(w http.ResponseWriter, r *http.Request) {
+	b.handleFreeze(w, r, true)
+}
+
+// PostThaw is handler for POST /v{N}/thaw
+func (b *Backend) PostThaw(w http.ResponseWriter, r *http.Request) {
+	b.handleFreeze(w, r, false)
+}
+
+func (b *Backend) handleFreeze(w http.ResponseWriter, r *http.Request, frozen bool) {
+	if b.Freeze == nil {
+		httputil.WriteError(w, r, errors.New("freezing requires --cgroup-freezer"), http.StatusNotImplemented)
+		return
+	}
+	if err := b.Freeze(r.Context(), frozen); err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
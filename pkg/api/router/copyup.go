@@ -0,0 +1,34 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+// GetCopyUpDiff is handler for GET /v{N}/copy-up/diff
+func (b *Backend) GetCopyUpDiff(w http.ResponseWriter, r *http.Request) {
+	if b.CopyUpDiff == nil {
+		httputil.WriteError(w, r, errors.New("copy-up diff requires --copy-up=DIR:persist=PERSISTDIR"), http.StatusNotImplemented)
+		return
+	}
+	changes, err := b.CopyUpDiff()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []api.CopyUpChange{}
+	}
+	m, err := json.Marshal(changes)
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(m)
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+// NDJSONContentType is the content type of GetEvents.
+const NDJSONContentType = "application/x-ndjson"
+
+// GetEvents is the handler for GET /v{N}/events
+//
+// The events are streamed as newline-delimited JSON, until the parent shuts down or the client disconnects.
+// When the "since" query parameter (RFC 3339) is specified, the recent past events are sent first.
+func (b *Backend) GetEvents(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			httputil.WriteError(w, r, fmt.Errorf("bad since %q: %w", s, err), http.StatusBadRequest)
+			return
+		}
+	}
+	past, ch, cancel := b.Events.Subscribe(since)
+	defer cancel()
+	w.Header().Set("Content-Type", NDJSONContentType)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, ev := range past {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
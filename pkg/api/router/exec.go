@@ -0,0 +1,194 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/nsenter"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/pty"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/stdcopy"
+)
+
+// RawStreamContentType is the content type of the hijacked connection.
+// The stream is multiplexed with pkg/stdcopy.
+const RawStreamContentType = "application/vnd.rootlesskit.raw-stream"
+
+// PostExec is the handler for POST /v{N}/exec
+//
+// The connection is hijacked after the process is started.
+// The client sends stdin as stdcopy frames, and the server sends stdout, stderr, and the exit code.
+func (b *Backend) PostExec(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	var req api.ExecRequest
+	if err := decoder.Decode(&req); err != nil {
+		httputil.WriteError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if len(req.Args) == 0 {
+		httputil.WriteError(w, r, errors.New("no command specified"), http.StatusBadRequest)
+		return
+	}
+	cmd, err := nsenter.Command(nsenter.Opt{
+		TargetPID:  b.ChildPID,
+		NetNSPath:  b.DetachedNetNSPath,
+		WorkingDir: req.WorkingDir,
+	}, req.Args)
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	cmd.Env = append(append(os.Environ(), b.ExecEnv...), req.Env...)
+
+	if req.TTY {
+		b.execTTY(w, r, cmd, &req)
+	} else {
+		b.execNonTTY(w, r, cmd)
+	}
+}
+
+func (b *Backend) execTTY(w http.ResponseWriter, r *http.Request, cmd *exec.Cmd, req *api.ExecRequest) {
+	ptyMaster, ptySlave, err := pty.Open()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer ptyMaster.Close()
+	if err = pty.Resize(ptyMaster, req.Width, req.Height); err != nil {
+		logrus.WithError(err).Warn("exec: failed to resize the pty")
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = ptySlave, ptySlave, ptySlave
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	err = cmd.Start()
+	ptySlave.Close()
+	if err != nil {
+		httputil.WriteError(w, r, fmt.Errorf("failed to start %v: %w", cmd.Args, err), http.StatusInternalServerError)
+		return
+	}
+	conn, connR, err := hijack(w)
+	if err != nil {
+		logrus.WithError(err).Warn("exec: failed to hijack the connection")
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return
+	}
+	defer conn.Close()
+	mux := stdcopy.NewMuxer(conn)
+	go func() {
+		if err := stdcopy.DemuxStdin(connR, ptyMaster); err != nil {
+			logrus.WithError(err).Debug("exec: stdin")
+		}
+	}()
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		// EIO is returned after the process exits
+		_, _ = io.Copy(mux.Writer(stdcopy.Stdout), ptyMaster)
+	}()
+	_ = cmd.Wait()
+	<-outputDone
+	writeExitCode(mux, cmd)
+}
+
+func (b *Backend) execNonTTY(w http.ResponseWriter, r *http.Request, cmd *exec.Cmd) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		httputil.WriteError(w, r, fmt.Errorf("failed to start %v: %w", cmd.Args, err), http.StatusInternalServerError)
+		return
+	}
+	conn, connR, err := hijack(w)
+	if err != nil {
+		logrus.WithError(err).Warn("exec: failed to hijack the connection")
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return
+	}
+	defer conn.Close()
+	mux := stdcopy.NewMuxer(conn)
+	go func() {
+		if err := stdcopy.DemuxStdin(connR, stdin); err != nil {
+			logrus.WithError(err).Debug("exec: stdin")
+		}
+		stdin.Close()
+	}()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(mux.Writer(stdcopy.Stdout), stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(mux.Writer(stdcopy.Stderr), stderr)
+	}()
+	// StdoutPipe and StderrPipe must be fully read before calling Wait
+	wg.Wait()
+	_ = cmd.Wait()
+	writeExitCode(mux, cmd)
+}
+
+func writeExitCode(mux *stdcopy.Muxer, cmd *exec.Cmd) {
+	code := exitCode(cmd.ProcessState)
+	logrus.Debugf("exec: %v exited with %d", cmd.Args, code)
+	if err := mux.WriteExitCode(code); err != nil {
+		logrus.WithError(err).Debug("exec: failed to write the exit code")
+	}
+}
+
+func exitCode(st *os.ProcessState) int {
+	if st == nil {
+		return 255
+	}
+	if ws, ok := st.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return st.ExitCode()
+}
+
+// hijack hijacks the connection and writes the "101 UPGRADED" response.
+// The returned reader has to be used for reading from the connection, as it may contain buffered data.
+func hijack(w http.ResponseWriter) (net.Conn, io.Reader, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w)
+	}
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := fmt.Fprintf(bufrw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: %s\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n", RawStreamContentType); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := bufrw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, bufrw.Reader, nil
+}
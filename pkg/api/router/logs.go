@@ -0,0 +1,126 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/stdcopy"
+)
+
+func (b *Backend) onLogNil(w http.ResponseWriter, r *http.Request) {
+	httputil.WriteError(w, r, errors.New("not running in the detach mode"), http.StatusBadRequest)
+}
+
+// GetLogs is the handler for GET /v{N}/logs
+//
+// The stdout and the stderr of the child are returned as a plain text.
+// When the "follow" query parameter is true, the subsequent output is streamed until the child exits.
+func (b *Backend) GetLogs(w http.ResponseWriter, r *http.Request) {
+	if b.Log == nil {
+		b.onLogNil(w, r)
+		return
+	}
+	var follow bool
+	if s := r.URL.Query().Get("follow"); s != "" {
+		var err error
+		follow, err = strconv.ParseBool(s)
+		if err != nil {
+			httputil.WriteError(w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
+	current, ch, cancel, err := b.Log.Follow()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(current); err != nil || !follow {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case c, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(c.Data); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// PostAttach is the handler for POST /v{N}/attach
+//
+// The connection is hijacked, and the subsequent stdout and stderr of the child are sent as stdcopy frames.
+// The client sends stdin as stdcopy frames.
+// An empty stdin frame stops forwarding stdin, but does not close the stdin of the child,
+// as the stdin is shared across the clients.
+func (b *Backend) PostAttach(w http.ResponseWriter, r *http.Request) {
+	if b.Log == nil {
+		b.onLogNil(w, r)
+		return
+	}
+	_, ch, cancel, err := b.Log.Follow()
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+	conn, connR, err := hijack(w)
+	if err != nil {
+		logrus.WithError(err).Warn("attach: failed to hijack the connection")
+		return
+	}
+	defer conn.Close()
+	mux := stdcopy.NewMuxer(conn)
+	connClosed := make(chan struct{})
+	go func() {
+		defer close(connClosed)
+		if b.Stdin != nil {
+			if err := stdcopy.DemuxStdin(connR, b.Stdin); err != nil {
+				logrus.WithError(err).Debug("attach: stdin")
+				return
+			}
+		}
+		// Wait for the client to close the connection
+		for {
+			if _, _, err := stdcopy.ReadFrame(connR); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-connClosed:
+			return
+		case c, ok := <-ch:
+			if !ok {
+				return
+			}
+			t := stdcopy.Stdout
+			if c.Stderr {
+				t = stdcopy.Stderr
+			}
+			if _, err := mux.Writer(t).Write(c.Data); err != nil {
+				return
+			}
+		}
+	}
+}
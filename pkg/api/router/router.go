@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/logfile"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/version"
 )
@@ -20,6 +23,11 @@ type NetworkDriver interface {
 	Info(context.Context) (*api.NetworkDriverInfo, error)
 }
 
+// Log is implemented by *logfile.Writer
+type Log interface {
+	Follow() ([]byte, <-chan logfile.Chunk, func(), error)
+}
+
 // PortDriver is implemented by port.ParentDriver
 type PortDriver interface {
 	Info(context.Context) (*api.PortDriverInfo, error)
@@ -29,11 +37,57 @@ type PortDriver interface {
 type Backend struct {
 	StateDir string
 	ChildPID int
+	// DetachedNetNSPath is set only for the detach-netns mode.
+	// The path is relative to the root of the parent.
+	DetachedNetNSPath string
+	// ExecEnv is appended to the environment variables of the processes executed via PostExec.
+	ExecEnv []string
 	// NetworkDriver can be nil
 	NetworkDriver NetworkDriver
 	// PortDriver MUST be thread-safe.
 	// PortDriver can be nil
 	PortDriver PortDriver
+	// Log is set only for the detach mode.
+	Log Log
+	// Stdin is connected to the stdin of the child.
+	// Set only for the detach mode.
+	Stdin io.Writer
+	// Events can be nil
+	Events *events.Bus
+	// TargetRestarts returns the number of the restarts of the target command.
+	// TargetRestarts can be nil
+	TargetRestarts func() int
+	// Shutdown initiates the graceful shutdown of the child, and returns immediately.
+	// Shutdown can be nil
+	Shutdown func()
+	// Child can be nil
+	Child ChildController
+	// TimeNamespace is set only when the time namespace was created for the target command.
+	TimeNamespace *api.TimeNamespaceInfo
+	// Landlock returns the Landlock ABI version enforced for the target command,
+	// or nil until the child reports it.
+	// Landlock is set only when Landlock was requested for the target command.
+	Landlock func() *api.LandlockInfo
+	// IDMap is the effective uid_map and gid_map of the child.
+	// IDMap can be nil
+	IDMap *api.IDMapInfo
+	// Cgroup returns the current limits and usage of the cgroup of the child.
+	// Cgroup can be nil
+	Cgroup func() (*api.Cgroup, error)
+	// Freeze freezes the child, or thaws the child when frozen is false.
+	// Freeze blocks until the cgroup reports the state.
+	// Freeze can be nil
+	Freeze func(ctx context.Context, frozen bool) error
+	// CopyUpDiff returns the changes in the persistent copy-up layers.
+	// CopyUpDiff can be nil
+	CopyUpDiff func() ([]api.CopyUpChange, error)
+}
+
+// ChildController delegates the operations to the child.
+type ChildController interface {
+	// Sysctl gets the sysctl, or sets the sysctl when value is non-nil.
+	// Sysctl returns the current value.
+	Sysctl(ctx context.Context, key string, value *string) (string, error)
 }
 
 func (b *Backend) onPortDriverNil(w http.ResponseWriter, r *http.Request) {
@@ -113,10 +167,18 @@ func (b *Backend) DeletePort(w http.ResponseWriter, r *http.Request) {
 
 func (b *Backend) GetInfo(w http.ResponseWriter, r *http.Request) {
 	info := &api.Info{
-		APIVersion: api.Version,
-		Version:    version.Version,
-		StateDir:   b.StateDir,
-		ChildPID:   b.ChildPID,
+		APIVersion:    api.Version,
+		Version:       version.Version,
+		StateDir:      b.StateDir,
+		ChildPID:      b.ChildPID,
+		TimeNamespace: b.TimeNamespace,
+		IDMap:         b.IDMap,
+	}
+	if b.Landlock != nil {
+		info.Landlock = b.Landlock()
+	}
+	if b.TargetRestarts != nil {
+		info.TargetRestarts = b.TargetRestarts()
 	}
 	if b.NetworkDriver != nil {
 		ndInfo, err := b.NetworkDriver.Info(context.Background())
@@ -144,10 +206,31 @@ func (b *Backend) GetInfo(w http.ResponseWriter, r *http.Request) {
 	w.Write(m)
 }
 
+// PostShutdown is handler for POST /v{N}/shutdown
+func (b *Backend) PostShutdown(w http.ResponseWriter, r *http.Request) {
+	if b.Shutdown == nil {
+		httputil.WriteError(w, r, errors.New("shutdown is not supported"), http.StatusNotImplemented)
+		return
+	}
+	b.Shutdown()
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func AddRoutes(r *mux.Router, b *Backend) {
 	v1 := r.PathPrefix("/v1").Subrouter()
 	v1.Path("/ports").Methods("GET").HandlerFunc(b.GetPorts)
 	v1.Path("/ports").Methods("POST").HandlerFunc(b.PostPort)
 	v1.Path("/ports/{id}").Methods("DELETE").HandlerFunc(b.DeletePort)
 	v1.Path("/info").Methods("GET").HandlerFunc(b.GetInfo)
+	v1.Path("/shutdown").Methods("POST").HandlerFunc(b.PostShutdown)
+	v1.Path("/sysctls/{key}").Methods("GET").HandlerFunc(b.GetSysctl)
+	v1.Path("/sysctls/{key}").Methods("PUT").HandlerFunc(b.PutSysctl)
+	v1.Path("/exec").Methods("POST").HandlerFunc(b.PostExec)
+	v1.Path("/logs").Methods("GET").HandlerFunc(b.GetLogs)
+	v1.Path("/attach").Methods("POST").HandlerFunc(b.PostAttach)
+	v1.Path("/events").Methods("GET").HandlerFunc(b.GetEvents)
+	v1.Path("/cgroup").Methods("GET").HandlerFunc(b.GetCgroup)
+	v1.Path("/freeze").Methods("POST").HandlerFunc(b.PostFreeze)
+	v1.Path("/thaw").Methods("POST").HandlerFunc(b.PostThaw)
+	v1.Path("/copy-up/diff").Methods("GET").HandlerFunc(b.GetCopyUpDiff)
 }
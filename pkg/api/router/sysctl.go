@@ -0,0 +1,52 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/httputil"
+)
+
+func (b *Backend) onChildNil(w http.ResponseWriter, r *http.Request) {
+	httputil.WriteError(w, r, errors.New("the child does not support the control channel"), http.StatusNotImplemented)
+}
+
+// GetSysctl is handler for GET /v{N}/sysctls/{key}
+func (b *Backend) GetSysctl(w http.ResponseWriter, r *http.Request) {
+	b.handleSysctl(w, r, nil)
+}
+
+// PutSysctl is handler for PUT /v{N}/sysctls/{key}
+func (b *Backend) PutSysctl(w http.ResponseWriter, r *http.Request) {
+	var req api.Sysctl
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputil.WriteError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	b.handleSysctl(w, r, &req.Value)
+}
+
+func (b *Backend) handleSysctl(w http.ResponseWriter, r *http.Request, value *string) {
+	if b.Child == nil {
+		b.onChildNil(w, r)
+		return
+	}
+	key := mux.Vars(r)["key"]
+	v, err := b.Child.Sysctl(r.Context(), key, value)
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	m, err := json.Marshal(&api.Sysctl{Key: key, Value: v})
+	if err != nil {
+		httputil.WriteError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(m)
+}
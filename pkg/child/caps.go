@@ -0,0 +1,175 @@
+package child
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// capabilities are ordered by the numbers
+var capabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// supportedCaps returns the capabilities supported by both RootlessKit and the kernel,
+// and contained in the bounding set of the current process.
+func supportedCaps() []string {
+	last := len(capabilities) - 1
+	if b, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && n < last {
+			last = n
+		}
+	}
+	var res []string
+	for i, c := range capabilities[:last+1] {
+		if ok, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, uintptr(i), 0, 0, 0); err == nil && ok == 0 {
+			continue
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// normalizeCap converts "sys_admin" into "CAP_SYS_ADMIN".
+func normalizeCap(s string) (string, error) {
+	c := strings.ToUpper(s)
+	if c == "ALL" {
+		return c, nil
+	}
+	if !strings.HasPrefix(c, "CAP_") {
+		c = "CAP_" + c
+	}
+	if !slices.Contains(capabilities, c) {
+		return "", fmt.Errorf("unknown capability %q", s)
+	}
+	return c, nil
+}
+
+// TargetCaps returns the capabilities of the target command.
+// The capabilities in drop are dropped at first, and then the capabilities in add are added.
+// "ALL" can be used for both drop and add.
+// TargetCaps returns nil when both drop and add are empty.
+func TargetCaps(drop, add []string) ([]string, error) {
+	if len(drop) == 0 && len(add) == 0 {
+		return nil, nil
+	}
+	supported := supportedCaps()
+	set := make(map[string]bool)
+	for _, c := range supported {
+		set[c] = true
+	}
+	for _, s := range drop {
+		c, err := normalizeCap(s)
+		if err != nil {
+			return nil, err
+		}
+		if c == "ALL" {
+			clear(set)
+		} else {
+			delete(set, c)
+		}
+	}
+	for _, s := range add {
+		c, err := normalizeCap(s)
+		if err != nil {
+			return nil, err
+		}
+		if c == "ALL" {
+			for _, c := range supported {
+				set[c] = true
+			}
+		} else if slices.Contains(supported, c) {
+			set[c] = true
+		} else {
+			return nil, fmt.Errorf("capability %q is not supported by the kernel, or not in the bounding set", s)
+		}
+	}
+	// keep the order
+	res := []string{}
+	for _, c := range supported {
+		if set[c] {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+// applyCaps limits the bounding, effective, permitted, inheritable, and ambient capabilities
+// of the current process to caps.
+func applyCaps(caps []string) error {
+	var data [2]unix.CapUserData
+	for _, c := range supportedCaps() {
+		i := slices.Index(capabilities, c)
+		if slices.Contains(caps, c) {
+			data[i/32].Effective |= 1 << (i % 32)
+			continue
+		}
+		// CAP_SETPCAP is needed for dropping the bounding capabilities, so capset(2) is called after this loop
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(i), 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to drop %s from the bounding set: %w", c, err)
+		}
+	}
+	for i := range data {
+		data[i].Permitted = data[i].Effective
+		data[i].Inheritable = data[i].Effective
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("failed to set the capabilities: %w", err)
+	}
+	// The ambient capabilities are needed for the non-root target
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to clear the ambient capabilities: %w", err)
+	}
+	for _, c := range caps {
+		i := slices.Index(capabilities, c)
+		if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, uintptr(i), 0, 0); err != nil {
+			return fmt.Errorf("failed to raise %s in the ambient set: %w", c, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package child
+
+import (
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTargetCaps(t *testing.T) {
+	caps, err := TargetCaps(nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, caps == nil)
+
+	caps, err = TargetCaps([]string{"ALL"}, []string{"net_admin", "CAP_CHOWN"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"CAP_CHOWN", "CAP_NET_ADMIN"}, caps)
+
+	caps, err = TargetCaps([]string{"ALL"}, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{}, caps)
+
+	caps, err = TargetCaps([]string{"SYS_ADMIN"}, nil)
+	assert.NilError(t, err)
+	assert.Check(t, !slices.Contains(caps, "CAP_SYS_ADMIN"))
+	assert.Check(t, slices.Contains(caps, "CAP_CHOWN"))
+
+	_, err = TargetCaps([]string{"CAP_FOO"}, nil)
+	assert.ErrorContains(t, err, "unknown capability")
+}
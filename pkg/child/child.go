@@ -2,8 +2,10 @@ package child
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -16,9 +18,11 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/common"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/hook"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/seccomp"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/sigproxy"
 	sigproxysignal "github.com/rootless-containers/rootlesskit/v3/pkg/sigproxy/signal"
 	"github.com/sirupsen/logrus"
@@ -34,7 +38,8 @@ var propagationStates = map[string]uintptr{
 	"rslave":   uintptr(unix.MS_REC | unix.MS_SLAVE),
 }
 
-func setupFiles(cmd *exec.Cmd) {
+// listenFiles returns the files for the systemd socket activation sockets.
+func listenFiles() []*os.File {
 	// 0 1 and 2  are used for stdin. stdout, and stderr
 	const firstExtraFD = 3
 	systemdActivationFDs := 0
@@ -42,26 +47,41 @@ func setupFiles(cmd *exec.Cmd) {
 	if v := os.Getenv("LISTEN_FDS"); v != "" {
 		if num, err := strconv.Atoi(v); err == nil {
 			systemdActivationFDs = num
-			cmd.ExtraFiles = make([]*os.File, systemdActivationFDs)
 		}
 	}
+	var files []*os.File
 	for fd := 0; fd < systemdActivationFDs; fd++ {
-		cmd.ExtraFiles[fd] = os.NewFile(uintptr(firstExtraFD+fd), "")
+		files = append(files, os.NewFile(uintptr(firstExtraFD+fd), ""))
 	}
+	return files
 }
 
-func createCmd(opt Opt) (*exec.Cmd, error) {
+// useActivationHelper returns whether the activation helper is needed for fixing $LISTEN_PID.
+// The env var is unset so that it is not propagated to the target command.
+func useActivationHelper(opt Opt) bool {
 	fixListenPidEnv, err := strconv.ParseBool(os.Getenv(opt.ChildUseActivationEnvKey))
 	if err != nil {
 		fixListenPidEnv = false
 	}
 	os.Unsetenv(opt.ChildUseActivationEnvKey)
+	return fixListenPidEnv
+}
+
+// createCmd creates the target command.
+// createCmd may be called multiple times for restarting the target.
+func createCmd(opt Opt, fixListenPidEnv bool, extraFiles []*os.File) (*exec.Cmd, error) {
 	targetCmd := opt.TargetCmd
 	var cmd *exec.Cmd
 	cmdEnv := os.Environ()
-	if fixListenPidEnv {
+	useExecHelper := needsExecHelper(opt)
+	if fixListenPidEnv || useExecHelper {
 		cmd = exec.Command("/proc/self/exe", os.Args[1:]...)
-		cmdEnv = append(cmdEnv, opt.RunActivationHelperEnvKey+"=true")
+		if fixListenPidEnv {
+			cmdEnv = append(cmdEnv, opt.RunActivationHelperEnvKey+"=true")
+		}
+		if useExecHelper {
+			cmdEnv = append(cmdEnv, opt.RunExecHelperEnvKey+"=true")
+		}
 	} else {
 		var args []string
 		if len(targetCmd) > 1 {
@@ -76,7 +96,13 @@ func createCmd(opt Opt) (*exec.Cmd, error) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Pdeathsig: syscall.SIGKILL,
 	}
-	setupFiles(cmd)
+	if opt.KeepID {
+		// ExecHelper is executed as root, and switches to the caller's uid and gid
+		if err := setKeepID(cmd.SysProcAttr, useExecHelper); err != nil {
+			return nil, err
+		}
+	}
+	cmd.ExtraFiles = extraFiles
 	return cmd, nil
 }
 
@@ -186,14 +212,14 @@ func setupCopyDir(driver copyup.ChildDriver, dirs []string) (bool, error) {
 // setupNet sets up the network driver.
 //
 // NOTE: msg is altered during calling driver.ConfigureNetworkChild
-func setupNet(stateDir string, msg *messages.ParentInitNetworkDriverCompleted, etcWasCopied bool, driver network.ChildDriver, detachedNetNSPath string) error {
+func setupNet(stateDir string, msg *messages.ParentInitNetworkDriverCompleted, etcWasCopied bool, driver network.ChildDriver, detachedNetNSPath, domainname string) error {
 	// HostNetwork
 	if driver == nil {
 		return nil
 	}
 
 	stateDirResolvConf := filepath.Join(stateDir, "resolv.conf")
-	hostsContent, err := generateEtcHosts()
+	hostsContent, err := generateEtcHosts(domainname)
 	if err != nil {
 		return err
 	}
@@ -272,6 +298,8 @@ func setupNet(stateDir string, msg *messages.ParentInitNetworkDriverCompleted, e
 type Opt struct {
 	PipeFDEnvKey              string              // needs to be set
 	RunActivationHelperEnvKey string              // needs to be set
+	RunExecHelperEnvKey       string              // needs to be set
+	ExecHelperReportFDEnvKey  string              // needs to be set for reporting the enforced Landlock ABI version
 	ChildUseActivationEnvKey  string              // needs to be set
 	StateDirEnvKey            string              // needs to be set
 	TargetCmd                 []string            // needs to be set
@@ -283,7 +311,23 @@ type Opt struct {
 	MountProcfs               bool   // needs to be set if (and only if) parent.Opt.CreatePIDNS is set
 	Propagation               string // mount propagation type
 	Reaper                    bool
-	EvacuateCgroup2           bool // needs to correspond to parent.Opt.EvacuateCgroup2 is set
+	EvacuateCgroup2           bool        // needs to correspond to parent.Opt.EvacuateCgroup2 is set
+	Hooks                     []hook.Hook // the hooks of the parent phases are ignored
+	Restart                   RestartPolicy
+	StopSignal                syscall.Signal   // needs to correspond to parent.Opt.StopSignal
+	CreateTimeNS              bool             // create a time namespace for the target command
+	MonotonicOffset           time.Duration    // CLOCK_MONOTONIC offset of the time namespace
+	BoottimeOffset            time.Duration    // CLOCK_BOOTTIME offset of the time namespace
+	Hostname                  string           // optional, needs parent.Opt.CreateUTSNS
+	Domainname                string           // optional, needs parent.Opt.CreateUTSNS
+	SeccompProfile            *seccomp.Profile // optional, applied to the target command via ExecHelper
+	Caps                      []string         // nil for keeping the capabilities, see TargetCaps
+	NoNewPrivileges           bool
+	LandlockRO                []string // read-only paths for Landlock
+	LandlockRW                []string // read-write paths for Landlock
+	Rlimits                   []Rlimit // validated with ValidateRlimit
+	OOMScoreAdj               *int     // nil for keeping the value, validated with ValidateOOMScoreAdj
+	KeepID                    bool     // execute the target command in a nested user namespace, as the caller's uid and gid
 }
 
 // statPIDNS is from https://github.com/containerd/containerd/blob/v1.7.2/services/introspection/pidns_linux.go#L25-L36
@@ -350,7 +394,62 @@ func gainCaps() error {
 	panic("should not reach here")
 }
 
-func Child(opt Opt) error {
+// The phases reported to the parent via messages.ChildError.
+// Needs to correspond to parent.Phase.
+const (
+	phaseUserNS  = "userns"
+	phaseMount   = "mount"
+	phaseCopyUp  = "copy-up"
+	phaseNetwork = "network"
+	phasePort    = "port"
+	phaseExec    = "exec"
+)
+
+// sendChildError sends messages.ChildError to the parent.
+func sendChildError(w io.Writer, phase string, err error) {
+	m := &messages.ChildError{
+		Phase:        phase,
+		ErrorMessage: err.Error(),
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		m.Errno = int(errno)
+	}
+	msg := &messages.Message{
+		U: messages.U{
+			ChildError: m,
+		},
+	}
+	if sendErr := messages.Send(w, msg); sendErr != nil {
+		logrus.WithError(sendErr).Debug("failed to send the error to the parent")
+	}
+}
+
+// parentPeerEnvKey is the environment variable for propagating the parent peer to the re-executed child.
+const parentPeerEnvKey = "_ROOTLESSKIT_PARENT_PEER"
+
+func setParentPeerEnv(p *messages.Peer) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.Setenv(parentPeerEnvKey, string(b))
+}
+
+// parentPeerFromEnv returns the parent peer propagated from the child before the re-execution.
+// The environment variable is unset so that it is not propagated to the target command.
+func parentPeerFromEnv() (*messages.Peer, error) {
+	var p messages.Peer
+	if s := os.Getenv(parentPeerEnvKey); s != "" {
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			return nil, fmt.Errorf("failed to parse %s value %q: %w", parentPeerEnvKey, s, err)
+		}
+	}
+	os.Unsetenv(parentPeerEnvKey)
+	return &p, nil
+}
+
+func Child(opt Opt) (retErr error) {
 	if opt.PipeFDEnvKey == "" {
 		return errors.New("pipe FD env key is not set")
 	}
@@ -364,7 +463,18 @@ func Child(opt Opt) error {
 	}
 	logrus.Debugf("pipeFD=%d, pipe2FD=%d", pipeFD, pipe2FD)
 	pipeR := os.NewFile(uintptr(pipeFD), "")
-	pipe2W := os.NewFile(uintptr(pipe2FD), "")
+	// pipe2W is written from multiple goroutines after the initialization
+	pipe2W := &syncWriter{w: os.NewFile(uintptr(pipe2FD), "")}
+	// parentPeer is nil until ParentHello is received
+	var parentPeer *messages.Peer
+	// phase is reported to the parent on an error.
+	// phase is cleared before running the target command, as the exit status of the target is not an error of the child.
+	phase := phaseUserNS
+	defer func() {
+		if retErr != nil && phase != "" && parentPeer.Can(messages.Name(messages.ChildError{})) {
+			sendChildError(pipe2W, phase, retErr)
+		}
+	}()
 
 	if opt.StateDirEnvKey == "" {
 		opt.StateDirEnvKey = "ROOTLESSKIT_STATE_DIR" // for backward compatibility of Go API
@@ -388,12 +498,29 @@ func Child(opt Opt) error {
 
 		msgChildHello := &messages.Message{
 			U: messages.U{
-				ChildHello: &messages.ChildHello{},
+				ChildHello: &messages.ChildHello{
+					ChildProtocolVersion:    messages.ProtocolVersion,
+					ChildMinProtocolVersion: messages.MinProtocolVersion,
+					ChildCapabilities:       messages.ChildCapabilities,
+				},
 			},
 		}
 		if err := messages.Send(pipe2W, msgChildHello); err != nil {
 			return err
 		}
+		parentHello := msg.U.ParentHello
+		if parentHello == nil {
+			// sent from RootlessKit prior to v3.1.0
+			parentHello = &messages.ParentHello{}
+		}
+		parentPeer, err = messages.NewPeer("parent", parentHello.ParentProtocolVersion, parentHello.ParentMinProtocolVersion, parentHello.ParentCapabilities)
+		if err != nil {
+			return err
+		}
+		// parentPeer is propagated to the re-executed child
+		if err := setParentPeerEnv(parentPeer); err != nil {
+			return err
+		}
 
 		msg, err = messages.WaitFor(pipeR, messages.Name(messages.ParentInitIdmapCompleted{}))
 		if err != nil {
@@ -405,6 +532,19 @@ func Child(opt Opt) error {
 		}
 	}
 
+	if parentPeer == nil {
+		parentPeer, err = parentPeerFromEnv()
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("parent: protocol version %d, capabilities %v", parentPeer.ProtocolVersion, parentPeer.Capabilities)
+
+	// The pipes must not be inherited to the target command, so that the parent can detect EOF.
+	// Not set before gainCaps, as the pipes have to be inherited on re-execution.
+	unix.CloseOnExec(pipeFD)
+	unix.CloseOnExec(pipe2FD)
+
 	if opt.MountProcfs {
 		if err := mountProcfs(); err != nil {
 			return err
@@ -419,6 +559,14 @@ func Child(opt Opt) error {
 		}
 	}
 
+	hookState := hook.State{
+		StateDir:      stateDir,
+		DetachedNetNS: detachedNetNSPath,
+	}
+	if err := hook.Run(context.TODO(), opt.Hooks, hook.PhaseUserNS, hookState); err != nil {
+		return err
+	}
+
 	msgChildInitUserNSCompleted := &messages.Message{
 		U: messages.U{
 			ChildInitUserNSCompleted: &messages.ChildInitUserNSCompleted{},
@@ -443,22 +591,37 @@ func Child(opt Opt) error {
 	// The parent calls child with Pdeathsig, but it is cleared when newuidmap SUID binary is called
 	// https://github.com/rootless-containers/rootlesskit/issues/65#issuecomment-492343646
 	os.Unsetenv(opt.PipeFDEnvKey)
-	if err := pipeR.Close(); err != nil {
-		return fmt.Errorf("failed to close fd %d: %w", pipeFD, err)
+	// pipeR is kept open for the control channel, if the parent supports it
+	useControl := parentPeer.Can(messages.Name(messages.ChildResponse{}))
+	if !useControl {
+		if err := pipeR.Close(); err != nil {
+			return fmt.Errorf("failed to close fd %d: %w", pipeFD, err)
+		}
 	}
+	phase = phaseMount
 	if err := setMountPropagation(opt.Propagation); err != nil {
 		return err
 	}
+	phase = phaseCopyUp
 	etcWasCopied, err := setupCopyDir(opt.CopyUpDriver, opt.CopyUpDirs)
 	if err != nil {
 		return err
 	}
 	if detachedNetNSPath == "" {
+		phase = phaseMount
 		if err := mountSysfs(opt.NetworkDriver == nil, opt.EvacuateCgroup2); err != nil {
 			return err
 		}
 	}
-	if err := setupNet(stateDir, netMsg, etcWasCopied, opt.NetworkDriver, detachedNetNSPath); err != nil {
+	phase = phaseNetwork
+	// The hostname is set before generating /etc/hosts
+	if err := setHostname(opt.Hostname, opt.Domainname); err != nil {
+		return err
+	}
+	if err := setupNet(stateDir, netMsg, etcWasCopied, opt.NetworkDriver, detachedNetNSPath, opt.Domainname); err != nil {
+		return err
+	}
+	if err := hook.Run(context.TODO(), opt.Hooks, hook.PhaseNetwork, hookState); err != nil {
 		return err
 	}
 	portQuitCh := make(chan struct{})
@@ -473,57 +636,108 @@ func Child(opt Opt) error {
 		}()
 	}
 
-	cmd, err := createCmd(opt)
-	if err != nil {
+	if useControl {
+		cs := &controlServer{
+			w:                 pipe2W,
+			detachedNetNSPath: detachedNetNSPath,
+		}
+		go cs.serve(pipeR)
+	}
+
+	phase = phaseExec
+	if err := validateExecHelper(opt); err != nil {
 		return err
 	}
+	// The activation sockets are opened just once, as they are reused on restarting the target
+	fixListenPidEnv := useActivationHelper(opt)
+	extraFiles := listenFiles()
+	reportLandlock := (len(opt.LandlockRO) != 0 || len(opt.LandlockRW) != 0) && opt.ExecHelperReportFDEnvKey != "" &&
+		parentPeer.Can(messages.Name(messages.ChildLandlockApplied{}))
+	onLandlockApplied := func(abi int) {
+		msg := &messages.Message{
+			U: messages.U{
+				ChildLandlockApplied: &messages.ChildLandlockApplied{
+					LandlockABI: abi,
+				},
+			},
+		}
+		if err := messages.Send(pipe2W, msg); err != nil {
+			logrus.WithError(err).Warn("failed to notify the Landlock ABI version to the parent")
+		}
+	}
+	// closeLandlockReport is called after each run of the target command
+	closeLandlockReport := func() {}
+	newCmd := func() (*exec.Cmd, error) {
+		cmd, err := createCmd(opt, fixListenPidEnv, extraFiles)
+		if err != nil || !reportLandlock {
+			return cmd, err
+		}
+		closeLandlockReport, err = reportLandlockABI(cmd, opt.ExecHelperReportFDEnvKey, onLandlockApplied)
+		return cmd, err
+	}
+	onRestart := func(restarts, exitCode int) {
+		if !parentPeer.Can(messages.Name(messages.ChildTargetRestarted{})) {
+			return
+		}
+		msg := &messages.Message{
+			U: messages.U{
+				ChildTargetRestarted: &messages.ChildTargetRestarted{
+					Restarts: restarts,
+					ExitCode: exitCode,
+				},
+			},
+		}
+		if err := messages.Send(pipe2W, msg); err != nil {
+			logrus.WithError(err).Warn("failed to notify the restart to the parent")
+		}
+	}
+	runCmd := runWithoutReap
+	if opt.Reaper {
+		runCmd = runAndReap
+	}
+	run := func(cmd *exec.Cmd) error {
+		defer closeLandlockReport()
+		return runCmd(cmd)
+	}
 
 	// Create a channel to receive errors from the goroutine
 	cmdErrCh := make(chan error, 1)
 
-	if opt.Reaper {
-		// Launch a goroutine to execute the command with Pdeathsig
-		go func() {
-			// Lock the goroutine to the OS thread
-			runtime.LockOSThread()
-			defer runtime.UnlockOSThread()
-
-			// Set the parent death signal
-			if err := unix.Prctl(unix.PR_SET_PDEATHSIG, uintptr(unix.SIGKILL), 0, 0, 0); err != nil {
+	// Launch a goroutine to execute the command with Pdeathsig
+	go func() {
+		// Lock the goroutine to the OS thread
+		runtime.LockOSThread()
+		if opt.CreateTimeNS {
+			// The time namespace is created for the children of this thread.
+			// The thread is not unlocked, so that it is terminated on exiting the goroutine.
+			if err := unshareTimeNS(opt.MonotonicOffset, opt.BoottimeOffset); err != nil {
 				cmdErrCh <- err
 				return
 			}
-
-			// Run the command
-			cmdErrCh <- runAndReap(cmd)
-		}()
-
-		// Wait for the command to complete
-		if err := <-cmdErrCh; err != nil {
-			return fmt.Errorf("command %v exited: %w", opt.TargetCmd, err)
-		}
-	} else {
-		// Launch a goroutine to execute the command with Pdeathsig
-		go func() {
-			// Lock the goroutine to the OS thread
-			runtime.LockOSThread()
+		} else {
 			defer runtime.UnlockOSThread()
+		}
 
-			// Set the parent death signal
-			if err := unix.Prctl(unix.PR_SET_PDEATHSIG, uintptr(unix.SIGKILL), 0, 0, 0); err != nil {
-				cmdErrCh <- err
-				return
-			}
+		// Set the parent death signal
+		if err := unix.Prctl(unix.PR_SET_PDEATHSIG, uintptr(unix.SIGKILL), 0, 0, 0); err != nil {
+			cmdErrCh <- err
+			return
+		}
 
-			// Run the command without reaping
-			cmdErrCh <- runWithoutReap(cmd)
-		}()
+		// Run the command, with restarting on a failure if the restart policy is set
+		cmdErrCh <- superviseCmd(opt.Restart, opt.StopSignal, newCmd, run, onRestart)
+	}()
 
-		// Wait for the command to complete
-		if err := <-cmdErrCh; err != nil {
-			return fmt.Errorf("command %v exited: %w", opt.TargetCmd, err)
+	// Wait for the command to complete
+	if err := <-cmdErrCh; err != nil {
+		var errWithSys common.ErrorWithSys
+		if errors.As(err, &errWithSys) {
+			// The target command was executed, and exited with a non-zero status
+			phase = ""
 		}
+		return fmt.Errorf("command %v exited: %w", opt.TargetCmd, err)
 	}
+	phase = phasePort
 	if opt.PortDriver != nil {
 		portQuitCh <- struct{}{}
 		return <-portErrCh
@@ -578,7 +792,11 @@ func runAndReap(cmd *exec.Cmd) error {
 			}
 		}
 	}()
-	return <-result
+	err := <-result
+	// Stop the goroutine above, as runAndReap may be called again for restarting the target
+	signal.Stop(c)
+	close(c)
+	return err
 }
 
 func reap(myPid int) *syscall.WaitStatus {
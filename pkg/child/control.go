@@ -0,0 +1,130 @@
+package child
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
+)
+
+// syncWriter serializes the writes of the messages.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// controlServer serves ParentRequest after the initialization.
+type controlServer struct {
+	w                 io.Writer
+	detachedNetNSPath string
+}
+
+// serve serves the requests until r is closed.
+func (s *controlServer) serve(r io.Reader) {
+	for {
+		msg, err := messages.Recv(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+				logrus.WithError(err).Debug("stopped receiving requests from the parent")
+			}
+			return
+		}
+		if msg.Name != messages.Name(messages.ParentRequest{}) || msg.U.ParentRequest == nil {
+			logrus.Debugf("Skipping unexpected message %q", msg.Name)
+			continue
+		}
+		go s.handle(msg.U.ParentRequest)
+	}
+}
+
+func (s *controlServer) handle(req *messages.ParentRequest) {
+	resp := &messages.ChildResponse{
+		ResponseID: req.RequestID,
+	}
+	res, err := s.do(req.RequestOp, req.RequestArgs)
+	if err == nil {
+		resp.ResponseResult, err = json.Marshal(res)
+	}
+	if err != nil {
+		resp.ResponseError = err.Error()
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			resp.ResponseErrno = int(errno)
+		}
+	}
+	msg := &messages.Message{
+		U: messages.U{
+			ChildResponse: resp,
+		},
+	}
+	if err := messages.Send(s.w, msg); err != nil {
+		logrus.WithError(err).Warn("failed to send the response to the parent")
+	}
+}
+
+func (s *controlServer) do(op string, rawArgs json.RawMessage) (interface{}, error) {
+	switch op {
+	case messages.OpSysctl:
+		var args messages.SysctlArgs
+		if err := json.Unmarshal(rawArgs, &args); err != nil {
+			return nil, err
+		}
+		return sysctl(args, s.detachedNetNSPath)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op)
+	}
+}
+
+var sysctlKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+
+// sysctl gets or sets the sysctl.
+// The "net." sysctls are set in the detached netns when detachedNetNSPath is set.
+func sysctl(args messages.SysctlArgs, detachedNetNSPath string) (*messages.SysctlResult, error) {
+	if !sysctlKeyRegexp.MatchString(args.Key) {
+		return nil, fmt.Errorf("invalid sysctl key %q", args.Key)
+	}
+	p := filepath.Join("/proc/sys", strings.ReplaceAll(args.Key, ".", "/"))
+	var res messages.SysctlResult
+	f := func() error {
+		if args.Value != nil {
+			if err := os.WriteFile(p, []byte(*args.Value), 0644); err != nil {
+				return err
+			}
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		res.Value = strings.TrimSpace(string(b))
+		return nil
+	}
+	var err error
+	if detachedNetNSPath != "" && strings.HasPrefix(args.Key, "net.") {
+		err = ns.WithNetNSPath(detachedNetNSPath, func(_ ns.NetNS) error {
+			return f()
+		})
+	} else {
+		err = f()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
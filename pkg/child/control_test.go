@@ -0,0 +1,54 @@
+package child
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
+)
+
+func TestControlServer(t *testing.T) {
+	reqR, reqW, err := os.Pipe()
+	assert.NilError(t, err)
+	defer reqW.Close()
+	respR, respW, err := os.Pipe()
+	assert.NilError(t, err)
+	defer respR.Close()
+	cs := &controlServer{w: &syncWriter{w: respW}}
+	go cs.serve(reqR)
+
+	send := func(id uint64, op string, args interface{}) *messages.ChildResponse {
+		rawArgs, err := json.Marshal(args)
+		assert.NilError(t, err)
+		msg := &messages.Message{
+			U: messages.U{
+				ParentRequest: &messages.ParentRequest{
+					RequestID:   id,
+					RequestOp:   op,
+					RequestArgs: rawArgs,
+				},
+			},
+		}
+		assert.NilError(t, messages.Send(reqW, msg))
+		resp, err := messages.WaitFor(respR, messages.Name(messages.ChildResponse{}))
+		assert.NilError(t, err)
+		assert.Equal(t, id, resp.U.ChildResponse.ResponseID)
+		return resp.U.ChildResponse
+	}
+
+	resp := send(1, messages.OpSysctl, &messages.SysctlArgs{Key: "kernel.ostype"})
+	assert.Equal(t, "", resp.ResponseError)
+	var res messages.SysctlResult
+	assert.NilError(t, json.Unmarshal(resp.ResponseResult, &res))
+	assert.Equal(t, "Linux", res.Value)
+
+	resp = send(2, messages.OpSysctl, &messages.SysctlArgs{Key: "../etc/passwd"})
+	assert.Assert(t, strings.Contains(resp.ResponseError, "invalid sysctl key"), resp.ResponseError)
+
+	resp = send(3, "foo", nil)
+	assert.Assert(t, strings.Contains(resp.ResponseError, "unknown operation"), resp.ResponseError)
+}
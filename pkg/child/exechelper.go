@@ -0,0 +1,198 @@
+package child
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"syscall"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/landlock"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/seccomp"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/systemd/activation"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// ExecHelperOpt is the option for ExecHelper.
+type ExecHelperOpt struct {
+	RunExecHelperEnvKey string           // needs to be set
+	ReportFDEnvKey      string           // needs to be set for reporting the enforced Landlock ABI version
+	TargetCmd           []string         // needs to be set
+	SeccompProfile      *seccomp.Profile // optional
+	Caps                []string         // nil for keeping the capabilities, see TargetCaps
+	NoNewPrivileges     bool
+	LandlockRO          []string // read-only paths for Landlock
+	LandlockRW          []string // read-write paths for Landlock
+	Rlimits             []Rlimit
+	OOMScoreAdj         *int // nil for keeping the value
+	KeepID              bool // switch to the caller's uid and gid, see Opt.KeepID
+	// Activation is set when the activation helper has to be run too.
+	Activation *activation.Opt
+}
+
+// needsExecHelper returns whether the target command has to be executed via ExecHelper.
+func needsExecHelper(opt Opt) bool {
+	return opt.SeccompProfile != nil || opt.Caps != nil || opt.NoNewPrivileges ||
+		len(opt.LandlockRO) != 0 || len(opt.LandlockRW) != 0 || len(opt.Rlimits) != 0 || opt.OOMScoreAdj != nil
+}
+
+// reportLandlockABI makes ExecHelper executed by cmd report the enforced Landlock ABI version,
+// via a pipe passed as an extra file. report is called from a goroutine.
+// The returned function closes the pipe, and has to be called after cmd exits.
+func reportLandlockABI(cmd *exec.Cmd, envKey string, report func(abi int)) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.ExtraFiles = append(slices.Clone(cmd.ExtraFiles), w)
+	// the extra files start from fd 3
+	cmd.Env = append(cmd.Env, envKey+"="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+	go func() {
+		var abi int
+		if _, err := fmt.Fscanln(r, &abi); err != nil {
+			// the pipe is closed when ExecHelper failed before enforcing Landlock
+			logrus.WithError(err).Debug("failed to receive the Landlock ABI version from the exec helper")
+			return
+		}
+		report(abi)
+	}()
+	return func() {
+		w.Close()
+		r.Close()
+	}, nil
+}
+
+// openReportFile opens the file for reporting the Landlock ABI version to the child.
+// openReportFile returns nil when the env var is not set.
+// The env var is unset, and the file is not inherited to the target command.
+func openReportFile(envKey string) (*os.File, error) {
+	if envKey == "" {
+		return nil, nil
+	}
+	s := os.Getenv(envKey)
+	os.Unsetenv(envKey)
+	if s == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected fd value: %s: %w", s, err)
+	}
+	unix.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), "report"), nil
+}
+
+// seccompCaps returns the capabilities for evaluating the seccomp profile.
+func seccompCaps(caps []string) []string {
+	if caps == nil {
+		return supportedCaps()
+	}
+	return caps
+}
+
+// validateExecHelper validates the settings of ExecHelper, so that the child fails
+// before executing the target command.
+func validateExecHelper(opt Opt) error {
+	if opt.SeccompProfile != nil {
+		if _, err := seccomp.Compile(opt.SeccompProfile, seccompCaps(opt.Caps)); err != nil {
+			return fmt.Errorf("failed to compile the seccomp profile: %w", err)
+		}
+	}
+	for _, p := range append(opt.LandlockRO, opt.LandlockRW...) {
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("invalid Landlock path: %w", err)
+		}
+	}
+	return nil
+}
+
+// ExecHelper applies the restrictions to the current process, and executes the target command.
+//
+// ExecHelper is executed as "/proc/self/exe" by the child, as the restrictions cannot be applied
+// between fork(2) and execve(2) of the target command in Go.
+// Landlock and the seccomp filter are applied at last when NoNewPrivileges is set, so that the filter does not need
+// to allow the syscalls of RootlessKit. Otherwise they are applied before dropping CAP_SYS_ADMIN.
+func ExecHelper(opt ExecHelperOpt) error {
+	// The capabilities and PR_SET_NO_NEW_PRIVS are per-thread attributes,
+	// so the target has to be executed from this thread.
+	runtime.LockOSThread()
+	os.Unsetenv(opt.RunExecHelperEnvKey)
+	reportFile, err := openReportFile(opt.ReportFDEnvKey)
+	if err != nil {
+		return err
+	}
+	if len(opt.TargetCmd) == 0 {
+		return errors.New("no command specified")
+	}
+	execPath, err := exec.LookPath(opt.TargetCmd[0])
+	if err != nil {
+		return err
+	}
+	var filter []unix.SockFilter
+	if opt.SeccompProfile != nil {
+		filter, err = seccomp.Compile(opt.SeccompProfile, seccompCaps(opt.Caps))
+		if err != nil {
+			return fmt.Errorf("failed to compile the seccomp profile: %w", err)
+		}
+	}
+	// The resource limits are applied before the seccomp filter, as the filter may deny setrlimit(2)
+	if err := applyRlimits(opt.Rlimits); err != nil {
+		return err
+	}
+	if opt.OOMScoreAdj != nil {
+		if err := applyOOMScoreAdj(*opt.OOMScoreAdj); err != nil {
+			return err
+		}
+	}
+	restrict := func() error {
+		if len(opt.LandlockRO) != 0 || len(opt.LandlockRW) != 0 {
+			abi, err := landlock.Restrict(opt.LandlockRO, opt.LandlockRW)
+			if err != nil {
+				return err
+			}
+			if reportFile != nil {
+				if _, err := fmt.Fprintln(reportFile, abi); err != nil {
+					logrus.WithError(err).Warn("failed to report the Landlock ABI version")
+				}
+				reportFile.Close()
+			}
+			if abi == 0 {
+				logrus.Warn("Landlock is not supported by the kernel, ignoring --landlock-ro and --landlock-rw")
+			}
+		}
+		// The seccomp filter is loaded after Landlock, as the filter may deny the syscalls of Landlock
+		return seccomp.Apply(filter)
+	}
+	if opt.NoNewPrivileges {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set PR_SET_NO_NEW_PRIVS: %w", err)
+		}
+	} else if err := restrict(); err != nil {
+		return err
+	}
+	if opt.KeepID {
+		if err := switchToKeepID(opt.Caps); err != nil {
+			return err
+		}
+	} else if opt.Caps != nil {
+		if err := applyCaps(opt.Caps); err != nil {
+			return err
+		}
+	}
+	if opt.NoNewPrivileges {
+		if err := restrict(); err != nil {
+			return err
+		}
+	}
+	if opt.Activation != nil {
+		return activation.ActivationHelper(*opt.Activation)
+	}
+	if err := syscall.Exec(execPath, opt.TargetCmd, os.Environ()); err != nil {
+		return fmt.Errorf("failed to execute %v: %w", opt.TargetCmd, err)
+	}
+	panic("should not reach here")
+}
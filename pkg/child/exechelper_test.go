@@ -0,0 +1,40 @@
+package child
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReportLandlockABI(t *testing.T) {
+	const envKey = "_ROOTLESSKIT_TEST_REPORT_FD"
+	cmd := exec.Command("sh", "-c", "echo 6 >&$"+envKey)
+	cmd.Env = os.Environ()
+	reported := make(chan int, 1)
+	closeReport, err := reportLandlockABI(cmd, envKey, func(abi int) { reported <- abi })
+	assert.NilError(t, err)
+	assert.NilError(t, cmd.Run())
+	defer closeReport()
+	select {
+	case abi := <-reported:
+		assert.Equal(t, 6, abi)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestOpenReportFile(t *testing.T) {
+	const envKey = "_ROOTLESSKIT_TEST_REPORT_FD"
+	f, err := openReportFile(envKey)
+	assert.NilError(t, err)
+	assert.Assert(t, f == nil)
+
+	t.Setenv(envKey, "foo")
+	_, err = openReportFile(envKey)
+	assert.ErrorContains(t, err, "unexpected fd value")
+	_, ok := os.LookupEnv(envKey)
+	assert.Assert(t, !ok)
+}
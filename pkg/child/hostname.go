@@ -0,0 +1,41 @@
+package child
+
+import (
+	"fmt"
+	"regexp"
+
+	"golang.org/x/sys/unix"
+)
+
+// hostnameMaxLen is HOST_NAME_MAX of Linux.
+const hostnameMaxLen = 64
+
+// hostnameRegexp matches a host name that consists of RFC 1123 labels.
+var hostnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+// ValidateHostname validates the hostname or the domainname.
+func ValidateHostname(s string) error {
+	if len(s) > hostnameMaxLen {
+		return fmt.Errorf("%q is longer than %d characters", s, hostnameMaxLen)
+	}
+	if !hostnameRegexp.MatchString(s) {
+		return fmt.Errorf("%q is not a valid host name", s)
+	}
+	return nil
+}
+
+// setHostname sets the hostname and the domainname of the UTS namespace.
+// Empty values are ignored.
+func setHostname(hostname, domainname string) error {
+	if hostname != "" {
+		if err := unix.Sethostname([]byte(hostname)); err != nil {
+			return fmt.Errorf("failed to set the hostname to %q: %w", hostname, err)
+		}
+	}
+	if domainname != "" {
+		if err := unix.Setdomainname([]byte(domainname)); err != nil {
+			return fmt.Errorf("failed to set the domainname to %q: %w", domainname, err)
+		}
+	}
+	return nil
+}
@@ -7,9 +7,10 @@ import (
 
 // generateEtcHosts makes sure the current hostname is resolved into
 // 127.0.0.1 or ::1, not into the host eth0 IP address.
+// When domainname is set, "<hostname>.<domainname>" is resolved too.
 //
 // Note that /etc/hosts is not used by nslookup/dig. (Use `getent ahostsv4` instead.)
-func generateEtcHosts() ([]byte, error) {
+func generateEtcHosts(domainname string) ([]byte, error) {
 	etcHosts, err := os.ReadFile("/etc/hosts")
 	if err != nil {
 		return nil, err
@@ -18,8 +19,16 @@ func generateEtcHosts() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return appendEtcHosts(etcHosts, hostname, domainname), nil
+}
+
+func appendEtcHosts(etcHosts []byte, hostname, domainname string) []byte {
+	names := hostname
+	if domainname != "" {
+		names = hostname + "." + domainname + " " + hostname
+	}
 	// FIXME: no need to add the entry if already added
 	s := fmt.Sprintf("%s\n127.0.0.1 %s\n::1 %s\n",
-		string(etcHosts), hostname, hostname)
-	return []byte(s), nil
+		string(etcHosts), names, names)
+	return []byte(s)
 }
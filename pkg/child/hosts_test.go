@@ -0,0 +1,25 @@
+package child
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAppendEtcHosts(t *testing.T) {
+	const etcHosts = "127.0.0.1 localhost\n"
+	assert.Equal(t, etcHosts+"\n127.0.0.1 foo\n::1 foo\n",
+		string(appendEtcHosts([]byte(etcHosts), "foo", "")))
+	assert.Equal(t, etcHosts+"\n127.0.0.1 foo.example.com foo\n::1 foo.example.com foo\n",
+		string(appendEtcHosts([]byte(etcHosts), "foo", "example.com")))
+}
+
+func TestValidateHostname(t *testing.T) {
+	for _, s := range []string{"foo", "foo-1", "foo.example.com", "1foo", strings.Repeat("a", 64)} {
+		assert.NilError(t, ValidateHostname(s), s)
+	}
+	for _, s := range []string{"", "-foo", "foo-", "foo..bar", "foo_bar", "foo bar", strings.Repeat("a", 65)} {
+		assert.Check(t, ValidateHostname(s) != nil, s)
+	}
+}
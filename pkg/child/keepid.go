@@ -0,0 +1,198 @@
+package child
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// secbitNoSetuidFixup is SECBIT_NO_SETUID_FIXUP of <linux/securebits.h>.
+const secbitNoSetuidFixup = 1 << 2
+
+type idMapEntry struct {
+	inside, outside, size int
+}
+
+func parseIDMap(r io.Reader) ([]idMapEntry, error) {
+	var res []idMapEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unparsable id map line %q", sc.Text())
+		}
+		var v [3]int
+		for i, f := range fields {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("unparsable id map line %q: %w", sc.Text(), err)
+			}
+			v[i] = n
+		}
+		res = append(res, idMapEntry{inside: v[0], outside: v[1], size: v[2]})
+	}
+	return res, sc.Err()
+}
+
+// keepIDMappings returns the mappings of the nested user namespace for --userns=keep-id,
+// from the uid_map or the gid_map of the current user namespace.
+//
+// The current user namespace is expected to map the host id of the caller to 0, and the subids from 1,
+// as in the default mode of RootlessKit.
+// The nested user namespace maps the host id of the caller to the same id, and maps the subids to
+// the other ids from 0.
+func keepIDMappings(current []idMapEntry) (int, []syscall.SysProcIDMap, error) {
+	var id, total int
+	for _, e := range current {
+		if e.inside != total {
+			return 0, nil, errors.New("keep-id requires contiguous id mappings")
+		}
+		if e.inside == 0 {
+			if e.size != 1 {
+				return 0, nil, errors.New("keep-id requires the caller to be mapped to 0")
+			}
+			id = e.outside
+		}
+		total += e.size
+	}
+	if total == 0 {
+		return 0, nil, errors.New("no id mapping")
+	}
+	// The ids from 1 in the current namespace are subids
+	subIDs := total - 1
+	maps := []syscall.SysProcIDMap{{ContainerID: id, HostID: 0, Size: 1}}
+	below := min(id, subIDs)
+	if below > 0 {
+		maps = append(maps, syscall.SysProcIDMap{ContainerID: 0, HostID: 1, Size: below})
+	}
+	if above := subIDs - below; above > 0 {
+		maps = append(maps, syscall.SysProcIDMap{ContainerID: id + 1, HostID: 1 + below, Size: above})
+	}
+	return id, maps, nil
+}
+
+func readKeepIDMappings(p string) (int, []syscall.SysProcIDMap, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	current, err := parseIDMap(f)
+	if err != nil {
+		return 0, nil, err
+	}
+	return keepIDMappings(current)
+}
+
+// setKeepID configures cmd to be executed in a nested user namespace for --userns=keep-id.
+// The command is executed as the caller's uid and gid, unless root is true.
+// root is needed for ExecHelper to apply the restrictions.
+func setKeepID(cmd *syscall.SysProcAttr, root bool) error {
+	uid, uidMaps, err := readKeepIDMappings("/proc/self/uid_map")
+	if err != nil {
+		return fmt.Errorf("failed to compute the uid map for keep-id: %w", err)
+	}
+	gid, gidMaps, err := readKeepIDMappings("/proc/self/gid_map")
+	if err != nil {
+		return fmt.Errorf("failed to compute the gid map for keep-id: %w", err)
+	}
+	setgroupsDenied, err := isSetgroupsDenied()
+	if err != nil {
+		return err
+	}
+	cmd.Cloneflags |= syscall.CLONE_NEWUSER
+	cmd.UidMappings = uidMaps
+	cmd.GidMappings = gidMaps
+	// setgroups cannot be allowed in the nested user namespace when it is denied in the current one
+	cmd.GidMappingsEnableSetgroups = !setgroupsDenied
+	if root {
+		// 0 is not mapped when no subid is mapped
+		if (uid != 0 && len(uidMaps) == 1) || (gid != 0 && len(gidMaps) == 1) {
+			return errors.New("keep-id with the restrictions (e.g., --seccomp-profile) requires subids")
+		}
+		uid, gid = 0, 0
+	}
+	cmd.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: setgroupsDenied}
+	return nil
+}
+
+// isSetgroupsDenied returns true when setgroups(2) is denied in the current user namespace,
+// e.g., with --subid-source=none.
+func isSetgroupsDenied() (bool, error) {
+	b, err := os.ReadFile("/proc/self/setgroups")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(b)) == "deny", nil
+}
+
+// callerID returns the id mapped to 0 of the parent user namespace, i.e., the host id of the caller,
+// from the uid_map or the gid_map of the nested user namespace.
+func callerID(p string) (int, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	current, err := parseIDMap(f)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range current {
+		if e.outside == 0 {
+			return e.inside, nil
+		}
+	}
+	return 0, fmt.Errorf("no id is mapped to 0 in %s", p)
+}
+
+// switchToKeepID switches the current process from the root of the nested user namespace
+// to the caller's uid and gid, for --userns=keep-id.
+// The capabilities are cleared as in setuid(2), unless caps is non-nil.
+func switchToKeepID(caps []string) error {
+	uid, err := callerID("/proc/self/uid_map")
+	if err != nil {
+		return err
+	}
+	gid, err := callerID("/proc/self/gid_map")
+	if err != nil {
+		return err
+	}
+	if caps != nil {
+		if err := unix.Prctl(unix.PR_SET_SECUREBITS, secbitNoSetuidFixup, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set SECBIT_NO_SETUID_FIXUP: %w", err)
+		}
+	}
+	setgroupsDenied, err := isSetgroupsDenied()
+	if err != nil {
+		return err
+	}
+	if !setgroupsDenied {
+		if err := syscall.Setgroups(nil); err != nil {
+			return fmt.Errorf("failed to clear the supplementary groups: %w", err)
+		}
+	}
+	if err := syscall.Setresgid(gid, gid, gid); err != nil {
+		return fmt.Errorf("failed to set the gid to %d: %w", gid, err)
+	}
+	if err := syscall.Setresuid(uid, uid, uid); err != nil {
+		return fmt.Errorf("failed to set the uid to %d: %w", uid, err)
+	}
+	if caps == nil {
+		return nil
+	}
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to clear the securebits: %w", err)
+	}
+	return applyCaps(caps)
+}
@@ -0,0 +1,32 @@
+package child
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestKeepIDMappings(t *testing.T) {
+	current, err := parseIDMap(strings.NewReader("         0       1001          1\n         1     231072      65536\n"))
+	assert.NilError(t, err)
+	id, maps, err := keepIDMappings(current)
+	assert.NilError(t, err)
+	assert.Equal(t, 1001, id)
+	expected := []syscall.SysProcIDMap{
+		{ContainerID: 1001, HostID: 0, Size: 1},
+		{ContainerID: 0, HostID: 1, Size: 1001},
+		{ContainerID: 1002, HostID: 1002, Size: 64535},
+	}
+	assert.DeepEqual(t, expected, maps)
+
+	// no subid
+	id, maps, err = keepIDMappings([]idMapEntry{{inside: 0, outside: 1001, size: 1}})
+	assert.NilError(t, err)
+	assert.Equal(t, 1001, id)
+	assert.DeepEqual(t, []syscall.SysProcIDMap{{ContainerID: 1001, HostID: 0, Size: 1}}, maps)
+
+	_, _, err = keepIDMappings([]idMapEntry{{inside: 0, outside: 1001, size: 2}})
+	assert.ErrorContains(t, err, "mapped to 0")
+}
@@ -0,0 +1,149 @@
+package child
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/common"
+	"github.com/sirupsen/logrus"
+)
+
+// RestartPolicy is the policy for restarting the target command in the same namespaces.
+type RestartPolicy struct {
+	OnFailure  bool
+	MaxRetries int // 0 for unlimited
+}
+
+func (p RestartPolicy) String() string {
+	if !p.OnFailure {
+		return "no"
+	}
+	if p.MaxRetries > 0 {
+		return fmt.Sprintf("on-failure:%d", p.MaxRetries)
+	}
+	return "on-failure"
+}
+
+// ParseRestartPolicy parses "no", "on-failure", or "on-failure:N".
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	name, maxStr, hasMax := strings.Cut(s, ":")
+	switch name {
+	case "", "no":
+		if hasMax {
+			return RestartPolicy{}, fmt.Errorf("restart policy %q does not take the maximum retry count", name)
+		}
+		return RestartPolicy{}, nil
+	case "on-failure":
+		p := RestartPolicy{OnFailure: true}
+		if hasMax {
+			n, err := strconv.Atoi(maxStr)
+			if err != nil || n <= 0 {
+				return RestartPolicy{}, fmt.Errorf("invalid maximum retry count %q", maxStr)
+			}
+			p.MaxRetries = n
+		}
+		return p, nil
+	default:
+		return RestartPolicy{}, fmt.Errorf("unknown restart policy %q", s)
+	}
+}
+
+const (
+	restartBackoffMin = 100 * time.Millisecond
+	restartBackoffMax = time.Minute
+	// restartBackoffReset is the duration of a successful run that resets the backoff.
+	restartBackoffReset = 10 * time.Second
+)
+
+// superviseCmd runs the command created by newCmd, and restarts it on a failure, according to policy.
+// onRestart is called with the total number of the restarts and the exit code of the previous run, on each restart.
+func superviseCmd(policy RestartPolicy, stopSignal syscall.Signal, newCmd func() (*exec.Cmd, error), run func(*exec.Cmd) error, onRestart func(restarts, exitCode int)) error {
+	// The target is not restarted after receiving SIGTERM, SIGINT, or the stop signal,
+	// even if the target exited with a non-zero status.
+	// stopping is closed on receiving the signal.
+	stopping := make(chan struct{})
+	if policy.OnFailure {
+		sigc := make(chan os.Signal, 1)
+		stopSignals := []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+		if stopSignal != 0 {
+			stopSignals = append(stopSignals, stopSignal)
+		}
+		signal.Notify(sigc, stopSignals...)
+		defer signal.Stop(sigc)
+		var once sync.Once
+		go func() {
+			for range sigc {
+				once.Do(func() { close(stopping) })
+			}
+		}()
+	}
+	backoff := restartBackoffMin
+	for restarts := 0; ; restarts++ {
+		cmd, err := newCmd()
+		if err != nil {
+			return err
+		}
+		started := time.Now()
+		err = run(cmd)
+		if err == nil || !policy.OnFailure || isClosed(stopping) {
+			return err
+		}
+		if policy.MaxRetries > 0 && restarts >= policy.MaxRetries {
+			return fmt.Errorf("%w (restarted %d times)", err, restarts)
+		}
+		var ee *exec.Error
+		if errors.As(err, &ee) {
+			// The command cannot be executed, so retrying makes no sense
+			return err
+		}
+		if time.Since(started) >= restartBackoffReset {
+			backoff = restartBackoffMin
+		}
+		logrus.Warnf("command %v exited (%v), restarting in %v", cmd.Args, err, backoff)
+		if !sleepUnlessClosed(backoff, stopping) {
+			return err
+		}
+		backoff = min(backoff*2, restartBackoffMax)
+		onRestart(restarts+1, exitCode(err))
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepUnlessClosed sleeps for d, and returns false without waiting for d when ch is closed.
+func sleepUnlessClosed(d time.Duration, ch <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return !isClosed(ch)
+	case <-ch:
+		return false
+	}
+}
+
+// exitCode returns the exit code of the command, or -1 if unknown (e.g., killed by a signal).
+func exitCode(err error) int {
+	var errWithSys common.ErrorWithSys
+	if errors.As(err, &errWithSys) {
+		if ws, ok := errWithSys.Sys().(syscall.WaitStatus); ok {
+			return ws.ExitStatus()
+		}
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package child
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	testCases := map[string]RestartPolicy{
+		"":              {},
+		"no":            {},
+		"on-failure":    {OnFailure: true},
+		"on-failure:10": {OnFailure: true, MaxRetries: 10},
+	}
+	for s, expected := range testCases {
+		p, err := ParseRestartPolicy(s)
+		assert.NilError(t, err, s)
+		assert.Equal(t, expected, p, s)
+	}
+	for _, s := range []string{"always", "no:1", "on-failure:0", "on-failure:foo"} {
+		_, err := ParseRestartPolicy(s)
+		assert.ErrorContains(t, err, "", s)
+	}
+}
+
+func TestSuperviseCmd(t *testing.T) {
+	var runs int
+	newCmd := func() (*exec.Cmd, error) {
+		runs++
+		return exec.Command("sh", "-c", "exit 42"), nil
+	}
+	run := func(cmd *exec.Cmd) error { return cmd.Run() }
+	var restarts, exitCodes []int
+	onRestart := func(n, exitCode int) {
+		restarts = append(restarts, n)
+		exitCodes = append(exitCodes, exitCode)
+	}
+	err := superviseCmd(RestartPolicy{OnFailure: true, MaxRetries: 2}, 0, newCmd, run, onRestart)
+	assert.ErrorContains(t, err, "restarted 2 times")
+	assert.Equal(t, 3, runs)
+	assert.DeepEqual(t, []int{1, 2}, restarts)
+	assert.DeepEqual(t, []int{42, 42}, exitCodes)
+}
+
+func TestSuperviseCmdStopSignal(t *testing.T) {
+	var runs int
+	newCmd := func() (*exec.Cmd, error) {
+		runs++
+		return exec.Command("sh", "-c", "exit 42"), nil
+	}
+	run := func(cmd *exec.Cmd) error {
+		err := cmd.Run()
+		// the target is not restarted after receiving the stop signal
+		assert.NilError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+		return err
+	}
+	onRestart := func(int, int) {}
+	err := superviseCmd(RestartPolicy{OnFailure: true}, syscall.SIGUSR1, newCmd, run, onRestart)
+	assert.ErrorContains(t, err, "exit status 42")
+	assert.Equal(t, 1, runs)
+}
+
+func TestSleepUnlessClosed(t *testing.T) {
+	ch := make(chan struct{})
+	assert.Assert(t, sleepUnlessClosed(10*time.Millisecond, ch))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(ch)
+	}()
+	started := time.Now()
+	assert.Assert(t, !sleepUnlessClosed(time.Minute, ch))
+	assert.Assert(t, time.Since(started) < 10*time.Second)
+}
@@ -0,0 +1,146 @@
+package child
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var rlimitTypes = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// Rlimit is a resource limit of the target command.
+type Rlimit struct {
+	Name string // e.g., "nofile"
+	Soft uint64 // unix.RLIM_INFINITY for "unlimited"
+	Hard uint64 // unix.RLIM_INFINITY for "unlimited"
+}
+
+func (r Rlimit) String() string {
+	return fmt.Sprintf("%s=%s:%s", r.Name, formatRlimitValue(r.Soft), formatRlimitValue(r.Hard))
+}
+
+func formatRlimitValue(v uint64) string {
+	if v == unix.RLIM_INFINITY {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func parseRlimitValue(s string) (uint64, error) {
+	if s == "unlimited" || s == "-1" {
+		return unix.RLIM_INFINITY, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rlimit value %q", s)
+	}
+	return v, nil
+}
+
+// ParseRlimit parses "NAME=SOFT:HARD" or "NAME=VALUE", e.g., "nofile=1024:65536".
+// "unlimited" can be used for the values.
+func ParseRlimit(s string) (Rlimit, error) {
+	name, values, ok := strings.Cut(s, "=")
+	if !ok {
+		return Rlimit{}, fmt.Errorf("invalid rlimit %q, expected NAME=SOFT:HARD", s)
+	}
+	name = strings.TrimPrefix(strings.ToLower(name), "rlimit_")
+	if _, ok := rlimitTypes[name]; !ok {
+		names := make([]string, 0, len(rlimitTypes))
+		for k := range rlimitTypes {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return Rlimit{}, fmt.Errorf("unknown rlimit %q, expected one of %v", name, names)
+	}
+	softStr, hardStr, hasHard := strings.Cut(values, ":")
+	if !hasHard {
+		hardStr = softStr
+	}
+	r := Rlimit{Name: name}
+	var err error
+	if r.Soft, err = parseRlimitValue(softStr); err != nil {
+		return r, err
+	}
+	if r.Hard, err = parseRlimitValue(hardStr); err != nil {
+		return r, err
+	}
+	if r.Soft > r.Hard {
+		return r, fmt.Errorf("rlimit %q: the soft limit is greater than the hard limit", s)
+	}
+	return r, nil
+}
+
+// ValidateRlimit validates that the limit does not exceed the hard limit of the current process,
+// as the hard limit cannot be raised in a user namespace.
+func ValidateRlimit(r Rlimit) error {
+	var cur unix.Rlimit
+	if err := unix.Getrlimit(rlimitTypes[r.Name], &cur); err != nil {
+		return fmt.Errorf("failed to get rlimit %q: %w", r.Name, err)
+	}
+	if r.Hard > cur.Max {
+		return fmt.Errorf("rlimit %q: the hard limit %s exceeds the current hard limit %s, which cannot be raised in the user namespace",
+			r.Name, formatRlimitValue(r.Hard), formatRlimitValue(cur.Max))
+	}
+	return nil
+}
+
+// applyRlimits sets the rlimits of the current process.
+func applyRlimits(rlimits []Rlimit) error {
+	for _, r := range rlimits {
+		lim := unix.Rlimit{Cur: r.Soft, Max: r.Hard}
+		if err := unix.Setrlimit(rlimitTypes[r.Name], &lim); err != nil {
+			return fmt.Errorf("failed to set rlimit %s: %w", r, err)
+		}
+	}
+	return nil
+}
+
+const (
+	oomScoreAdjMin = -1000
+	oomScoreAdjMax = 1000
+)
+
+// ValidateOOMScoreAdj validates the range of the value.
+func ValidateOOMScoreAdj(v int) error {
+	if v < oomScoreAdjMin || v > oomScoreAdjMax {
+		return fmt.Errorf("oom-score-adj must be in the range of [%d, %d], got %d", oomScoreAdjMin, oomScoreAdjMax, v)
+	}
+	return nil
+}
+
+// applyOOMScoreAdj sets the OOM score adjustment of the current process.
+func applyOOMScoreAdj(v int) error {
+	const p = "/proc/self/oom_score_adj"
+	if err := os.WriteFile(p, []byte(strconv.Itoa(v)), 0644); err != nil {
+		// Decreasing the value below oom_score_adj_min requires CAP_SYS_RESOURCE in the initial user namespace
+		cur := math.MinInt
+		if b, err := os.ReadFile(p); err == nil {
+			cur, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		}
+		return fmt.Errorf("failed to set oom_score_adj to %d (current: %d, cannot be decreased without the privilege): %w", v, cur, err)
+	}
+	return nil
+}
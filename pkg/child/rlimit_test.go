@@ -0,0 +1,51 @@
+package child
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseRlimit(t *testing.T) {
+	r, err := ParseRlimit("nofile=1024:65536")
+	assert.NilError(t, err)
+	assert.Equal(t, Rlimit{Name: "nofile", Soft: 1024, Hard: 65536}, r)
+
+	r, err = ParseRlimit("RLIMIT_CORE=unlimited")
+	assert.NilError(t, err)
+	assert.Equal(t, Rlimit{Name: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}, r)
+	assert.Equal(t, "core=unlimited:unlimited", r.String())
+
+	r, err = ParseRlimit("nproc=100:unlimited")
+	assert.NilError(t, err)
+	assert.Equal(t, Rlimit{Name: "nproc", Soft: 100, Hard: unix.RLIM_INFINITY}, r)
+
+	_, err = ParseRlimit("nofile")
+	assert.ErrorContains(t, err, "expected NAME=SOFT:HARD")
+
+	_, err = ParseRlimit("foo=1")
+	assert.ErrorContains(t, err, "unknown rlimit")
+
+	_, err = ParseRlimit("nofile=2:1")
+	assert.ErrorContains(t, err, "greater than the hard limit")
+
+	_, err = ParseRlimit("nofile=x")
+	assert.ErrorContains(t, err, "invalid rlimit value")
+}
+
+func TestValidateRlimit(t *testing.T) {
+	var cur unix.Rlimit
+	assert.NilError(t, unix.Getrlimit(unix.RLIMIT_NOFILE, &cur))
+	assert.NilError(t, ValidateRlimit(Rlimit{Name: "nofile", Soft: cur.Cur, Hard: cur.Max}))
+	if cur.Max != unix.RLIM_INFINITY {
+		err := ValidateRlimit(Rlimit{Name: "nofile", Soft: cur.Cur, Hard: cur.Max + 1})
+		assert.ErrorContains(t, err, "exceeds the current hard limit")
+	}
+}
+
+func TestValidateOOMScoreAdj(t *testing.T) {
+	assert.NilError(t, ValidateOOMScoreAdj(-1000))
+	assert.NilError(t, ValidateOOMScoreAdj(1000))
+	assert.ErrorContains(t, ValidateOOMScoreAdj(1001), "range")
+}
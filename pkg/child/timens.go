@@ -0,0 +1,62 @@
+package child
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// timensOffsetLine returns a line for /proc/PID/timens_offsets.
+// The nanoseconds are always non-negative, as required by the kernel.
+func timensOffsetLine(clock string, d time.Duration) string {
+	secs := int64(d / time.Second)
+	nsecs := int64(d % time.Second)
+	if nsecs < 0 {
+		secs--
+		nsecs += int64(time.Second)
+	}
+	return fmt.Sprintf("%s %d %d", clock, secs, nsecs)
+}
+
+// unshareTimeNS creates a time namespace for the children of the current thread,
+// and writes the clock offsets.
+//
+// The caller must lock the OS thread, and must start the target command from the same thread,
+// as the time namespace for the children is a per-thread attribute.
+// The offsets have to be written before any process enters the namespace.
+func unshareTimeNS(monotonic, boottime time.Duration) error {
+	if err := unix.Unshare(unix.CLONE_NEWTIME); err != nil {
+		return fmt.Errorf("failed to create a time namespace: %w", err)
+	}
+	var lines []string
+	if monotonic != 0 {
+		lines = append(lines, timensOffsetLine("monotonic", monotonic))
+	}
+	if boottime != 0 {
+		lines = append(lines, timensOffsetLine("boottime", boottime))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	// /proc/thread-self/timens_offsets does not exist, as timens_offsets is not a per-task file.
+	// /proc/<TID>/timens_offsets refers to the thread, not to the thread group leader.
+	// The TID is resolved via the procfs, as the procfs may belong to a PID namespace other than ours.
+	threadSelf, err := os.Readlink("/proc/thread-self") // "<TGID>/task/<TID>"
+	if err != nil {
+		return err
+	}
+	tid, err := strconv.Atoi(filepath.Base(threadSelf))
+	if err != nil {
+		return fmt.Errorf("unexpected /proc/thread-self: %q: %w", threadSelf, err)
+	}
+	p := fmt.Sprintf("/proc/%d/timens_offsets", tid)
+	if err := os.WriteFile(p, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write the clock offsets to %s: %w", p, err)
+	}
+	return nil
+}
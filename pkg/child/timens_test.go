@@ -0,0 +1,15 @@
+package child
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTimensOffsetLine(t *testing.T) {
+	assert.Equal(t, "monotonic 86400 0", timensOffsetLine("monotonic", 24*time.Hour))
+	assert.Equal(t, "boottime 1 500000000", timensOffsetLine("boottime", 1500*time.Millisecond))
+	assert.Equal(t, "monotonic -2 500000000", timensOffsetLine("monotonic", -1500*time.Millisecond))
+	assert.Equal(t, "monotonic -1 0", timensOffsetLine("monotonic", -time.Second))
+}
@@ -1,5 +1,41 @@
 package copyup
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 type ChildDriver interface {
 	CopyUp([]string) ([]string, error)
 }
+
+// Dir is a directory to be copied up.
+type Dir struct {
+	Path string
+	// PersistDir is the directory of the persistent writable layer, reused across the restarts.
+	// Empty for the throwaway writable layer.
+	PersistDir string
+}
+
+// ParseDir parses "PATH[:persist=DIR]", e.g., "/etc:persist=/home/penguin/.local/share/rootlesskit/etc".
+func ParseDir(s string) (Dir, error) {
+	path, opts, _ := strings.Cut(s, ":")
+	d := Dir{Path: filepath.Clean(path)}
+	if opts == "" {
+		return d, nil
+	}
+	for _, o := range strings.Split(opts, ",") {
+		k, v, _ := strings.Cut(o, "=")
+		switch k {
+		case "persist":
+			if !filepath.IsAbs(v) {
+				return d, fmt.Errorf("invalid copy-up %q: persist needs an absolute path", s)
+			}
+			d.PersistDir = filepath.Clean(v)
+		default:
+			return d, fmt.Errorf("invalid copy-up %q: unknown option %q", s, k)
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,23 @@
+package copyup
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDir(t *testing.T) {
+	d, err := ParseDir("/etc/")
+	assert.NilError(t, err)
+	assert.Equal(t, Dir{Path: "/etc"}, d)
+
+	d, err = ParseDir("/etc:persist=/home/penguin/.local/share/rootlesskit/etc/")
+	assert.NilError(t, err)
+	assert.Equal(t, Dir{Path: "/etc", PersistDir: "/home/penguin/.local/share/rootlesskit/etc"}, d)
+
+	_, err = ParseDir("/etc:persist=etc")
+	assert.ErrorContains(t, err, "absolute path")
+
+	_, err = ParseDir("/etc:foo=bar")
+	assert.ErrorContains(t, err, "unknown option")
+}
@@ -0,0 +1,88 @@
+package overlayfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+)
+
+// Diff returns the changes in the upper directory, compared to the lower directory.
+// The paths of the changes are joined with lower.
+//
+// The whiteouts of both overlayfs and fuse-overlayfs are reported as deleted.
+//
+// The paths that cannot be read, e.g., the directories owned by the subuids, are reported as unreadable,
+// and the walk continues without them.
+//
+// Diff is similar to `docker diff`: the parent directories of the changed files are reported as changed too.
+// The files under an opaque directory are not reported as deleted.
+func Diff(lower, upper string) ([]api.CopyUpChange, error) {
+	var changes []api.CopyUpChange
+	err := filepath.WalkDir(upper, func(p string, d fs.DirEntry, err error) error {
+		if err != nil && (p == upper || !errors.Is(err, fs.ErrPermission)) {
+			return err
+		}
+		rel, relErr := filepath.Rel(upper, p)
+		if relErr != nil {
+			return relErr
+		}
+		if err != nil {
+			// the directory failed to be read, after being reported by the previous call
+			path := filepath.Join(lower, rel)
+			if n := len(changes); n > 0 && changes[n-1].Path == path {
+				changes = changes[:n-1]
+			}
+			changes = append(changes, api.CopyUpChange{Path: path, Kind: api.CopyUpChangeUnreadable})
+			return fs.SkipDir
+		}
+		if rel == "." {
+			return nil
+		}
+		change := api.CopyUpChange{Path: filepath.Join(lower, rel)}
+		if name := d.Name(); !d.IsDir() && strings.HasPrefix(name, fuseWhiteoutPrefix) {
+			if name == fuseOpaqueWhiteout {
+				return nil
+			}
+			change.Path = filepath.Join(lower, filepath.Dir(rel), strings.TrimPrefix(name, fuseWhiteoutPrefix))
+			change.Kind = api.CopyUpChangeDeleted
+		} else if isWhiteout(p, d) {
+			change.Kind = api.CopyUpChangeDeleted
+		} else if _, err := os.Lstat(change.Path); err == nil {
+			change.Kind = api.CopyUpChangeChanged
+		} else if errors.Is(err, os.ErrNotExist) {
+			change.Kind = api.CopyUpChangeAdded
+		} else if errors.Is(err, os.ErrPermission) {
+			change.Kind = api.CopyUpChangeUnreadable
+		} else {
+			return err
+		}
+		changes = append(changes, change)
+		return nil
+	})
+	return changes, err
+}
+
+// The whiteouts of fuse-overlayfs, used when mknod(2) is not permitted.
+// A deleted file "foo" is represented by a file ".wh.foo", and an opaque directory contains ".wh..wh..opq".
+const (
+	fuseWhiteoutPrefix = ".wh."
+	fuseOpaqueWhiteout = ".wh..wh..opq"
+)
+
+// isWhiteout returns true for a whiteout of overlayfs, i.e., a character device with 0/0 device number.
+func isWhiteout(p string, d fs.DirEntry) bool {
+	if d.Type()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(p, &st); err != nil {
+		return false
+	}
+	return st.Rdev == 0
+}
@@ -0,0 +1,83 @@
+package overlayfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+)
+
+func TestDiff(t *testing.T) {
+	lower := filepath.Join(t.TempDir(), "lower")
+	upper := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(lower, "ssl"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(lower, "hosts"), []byte("foo"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(lower, "hostname"), []byte("foo"), 0644))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(upper, "hosts"), []byte("bar"), 0644))
+	assert.NilError(t, os.MkdirAll(filepath.Join(upper, "ssl", "foo"), 0755))
+	expected := []api.CopyUpChange{
+		{Path: filepath.Join(lower, "hosts"), Kind: api.CopyUpChangeChanged},
+		{Path: filepath.Join(lower, "ssl"), Kind: api.CopyUpChangeChanged},
+		{Path: filepath.Join(lower, "ssl", "foo"), Kind: api.CopyUpChangeAdded},
+	}
+	if err := unix.Mknod(filepath.Join(upper, "hostname"), unix.S_IFCHR, 0); err == nil {
+		expected = append([]api.CopyUpChange{{Path: filepath.Join(lower, "hostname"), Kind: api.CopyUpChangeDeleted}}, expected...)
+	} else {
+		t.Logf("skipping the whiteout test: %v", err)
+	}
+
+	changes, err := Diff(lower, upper)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, changes)
+}
+
+func TestDiffFuseOverlayfsWhiteouts(t *testing.T) {
+	lower := filepath.Join(t.TempDir(), "lower")
+	upper := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(lower, "ssl", "certs"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(lower, "hostname"), []byte("foo"), 0644))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(upper, ".wh.hostname"), nil, 0644))
+	assert.NilError(t, os.MkdirAll(filepath.Join(upper, "ssl"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(upper, "ssl", ".wh..wh..opq"), nil, 0644))
+	expected := []api.CopyUpChange{
+		{Path: filepath.Join(lower, "hostname"), Kind: api.CopyUpChangeDeleted},
+		{Path: filepath.Join(lower, "ssl"), Kind: api.CopyUpChangeChanged},
+	}
+
+	changes, err := Diff(lower, upper)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, changes)
+}
+
+func TestDiffUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("the root can read the unreadable directories")
+	}
+	lower := filepath.Join(t.TempDir(), "lower")
+	upper := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(lower, "ssl", "private"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(lower, "hosts"), []byte("foo"), 0644))
+
+	assert.NilError(t, os.MkdirAll(filepath.Join(upper, "ssl", "private", "foo"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(upper, "hosts"), []byte("bar"), 0644))
+	// e.g., a directory owned by a subuid
+	assert.NilError(t, os.Chmod(filepath.Join(upper, "ssl", "private"), 0))
+	t.Cleanup(func() {
+		_ = os.Chmod(filepath.Join(upper, "ssl", "private"), 0755)
+	})
+	expected := []api.CopyUpChange{
+		{Path: filepath.Join(lower, "hosts"), Kind: api.CopyUpChangeChanged},
+		{Path: filepath.Join(lower, "ssl"), Kind: api.CopyUpChangeChanged},
+		{Path: filepath.Join(lower, "ssl", "private"), Kind: api.CopyUpChangeUnreadable},
+	}
+
+	changes, err := Diff(lower, upper)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, changes)
+}
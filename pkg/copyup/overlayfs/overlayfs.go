@@ -0,0 +1,206 @@
+package overlayfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moby/sys/mountinfo"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup"
+)
+
+// NewChildDriver returns the copy-up driver that mounts overlayfs on each directory,
+// with the upper and the work directories on tmpfs.
+//
+// persistDirs maps the directories to the persistent directories, which contain the upper and the work
+// directories instead of tmpfs. persistDirs can be nil.
+//
+// The submounts of the directories are bind-mounted onto the overlay, as overlayfs does not show them.
+// In a user namespace, overlayfs cannot be mounted on a directory with submounts, so fuse-overlayfs is needed
+// for such a directory (e.g., /run with the tmpfs on /run/user/1001).
+//
+// Mounting overlayfs in a user namespace requires Linux 5.11 or later.
+// fuse-overlayfs is used instead when overlayfs cannot be mounted and fuse-overlayfs is installed.
+func NewChildDriver(persistDirs map[string]string) copyup.ChildDriver {
+	return &childDriver{persistDirs: persistDirs}
+}
+
+type childDriver struct {
+	persistDirs map[string]string
+}
+
+// UpperDir returns the upper directory in the persistent directory.
+func UpperDir(persistDir string) string {
+	return filepath.Join(persistDir, "upper")
+}
+
+func workDir(persistDir string) string {
+	return filepath.Join(persistDir, "work")
+}
+
+func (d *childDriver) CopyUp(dirs []string) ([]string, error) {
+	// we create tmp0 under /tmp, as the upper directories must not be under the copied-up directories.
+	// Copying up /run with stateDir=/run/user/1001/rootlesskit/default is allowed,
+	// as the tmpfs on /run/user/1001 is bind-mounted onto the overlay again.
+	tmp0, err := os.MkdirTemp("/tmp", "rootlesskit-o")
+	if err != nil {
+		return nil, fmt.Errorf("creating tmp0 directory under /tmp: %w", err)
+	}
+	defer os.RemoveAll(tmp0)
+	if err := unix.Mount("none", tmp0, "tmpfs", 0, ""); err != nil {
+		return nil, fmt.Errorf("failed to mount tmpfs on %s: %w", tmp0, err)
+	}
+	// The upper directories remain accessible to overlayfs after unmounting tmp0
+	defer unix.Unmount(tmp0, unix.MNT_DETACH)
+	persistDirs := d.persistDirs
+	var copied []string
+	for i, d := range dirs {
+		d := filepath.Clean(d)
+		if d == "/tmp" {
+			// TODO: we can support copy-up /tmp by changing tmp0
+			return copied, errors.New("/tmp cannot be copied up")
+		}
+		persistDir := persistDirs[d]
+		if persistDir == "" {
+			persistDir = filepath.Join(tmp0, strconv.Itoa(i))
+		}
+		upper, work := UpperDir(persistDir), workDir(persistDir)
+		if err := mkdirUpper(upper, d); err != nil {
+			return copied, err
+		}
+		// the work directory of the persistent directory is reused, as overlayfs cleans it up on mounting
+		if err := os.MkdirAll(work, 0700); err != nil {
+			return copied, err
+		}
+		subs, err := submounts(d)
+		if err != nil {
+			return copied, err
+		}
+		// bind0 keeps the submounts accessible after mounting the overlay on d
+		bind0 := filepath.Join(tmp0, "b"+strconv.Itoa(i))
+		if len(subs) > 0 {
+			if err := os.MkdirAll(bind0, 0700); err != nil {
+				return copied, err
+			}
+			if err := unix.Mount(d, bind0, "", uintptr(unix.MS_BIND|unix.MS_REC), ""); err != nil {
+				return copied, fmt.Errorf("failed to create bind mount on %s: %w", d, err)
+			}
+		}
+		if err := mountOverlay(d, upper, work, len(subs) > 0); err != nil {
+			return copied, err
+		}
+		for _, sub := range subs {
+			rel, err := filepath.Rel(d, sub)
+			if err != nil {
+				return copied, err
+			}
+			src := filepath.Join(bind0, rel)
+			if err := unix.Mount(src, sub, "", uintptr(unix.MS_BIND|unix.MS_REC), ""); err != nil {
+				return copied, fmt.Errorf("failed to create bind mount from %s to %s: %w", src, sub, err)
+			}
+		}
+		copied = append(copied, d)
+	}
+	return copied, nil
+}
+
+// submounts returns the mount points under dir.
+// The mount points under another returned mount point are omitted, as they are bind-mounted with MS_REC.
+func submounts(dir string) ([]string, error) {
+	mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the mounts under %s: %w", dir, err)
+	}
+	var mountpoints []string
+	for _, m := range mounts {
+		mountpoints = append(mountpoints, m.Mountpoint)
+	}
+	return topLevelSubmounts(dir, mountpoints), nil
+}
+
+func topLevelSubmounts(dir string, mountpoints []string) []string {
+	sort.Strings(mountpoints)
+	var res []string
+	for _, mp := range mountpoints {
+		if !isUnder(mp, dir) {
+			continue
+		}
+		top := true
+		for _, f := range res {
+			if mp == f || isUnder(mp, f) {
+				top = false
+				break
+			}
+		}
+		if top {
+			res = append(res, mp)
+		}
+	}
+	return res
+}
+
+// isUnder returns true if p is a strict descendant of dir.
+func isUnder(p, dir string) bool {
+	return p != dir && strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// mkdirUpper creates the upper directory with the mode and the owner of lower,
+// as the root of the overlay has the attributes of the upper directory.
+func mkdirUpper(upper, lower string) error {
+	var st unix.Stat_t
+	if err := unix.Stat(lower, &st); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", lower, err)
+	}
+	if err := os.MkdirAll(upper, 0700); err != nil {
+		return err
+	}
+	if err := os.Lchown(upper, int(st.Uid), int(st.Gid)); err != nil {
+		// EINVAL when the owner is not mapped in the user namespace (e.g., the root of the host)
+		if !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("failed to chown %s: %w", upper, err)
+		}
+		logrus.WithError(err).Debugf("failed to chown %s", upper)
+	}
+	// os.Chmod does not accept the raw mode bits
+	if err := unix.Chmod(upper, st.Mode&07777); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", upper, err)
+	}
+	return nil
+}
+
+// mountOverlay mounts overlayfs on dir, with dir itself as the lower directory.
+// hasSubmounts is used only for the error message.
+func mountOverlay(dir, upper, work string, hasSubmounts bool) error {
+	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", escape(dir), escape(upper), escape(work))
+	err := unix.Mount("overlay", dir, "overlay", 0, "userxattr,"+opts)
+	if err == nil {
+		return nil
+	}
+	fuseOverlayfs, lookErr := exec.LookPath("fuse-overlayfs")
+	if lookErr != nil {
+		if hasSubmounts && errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("failed to mount overlayfs on %s (needs fuse-overlayfs, as the submounts of %s cannot be the lower directory of overlayfs in a user namespace): %w", dir, dir, err)
+		}
+		return fmt.Errorf("failed to mount overlayfs on %s (needs Linux 5.11 or later, or fuse-overlayfs): %w", dir, err)
+	}
+	logrus.WithError(err).Debugf("failed to mount overlayfs on %s, falling back to fuse-overlayfs", dir)
+	// fuse-overlayfs runs in background after mounting the filesystem
+	cmd := exec.Command(fuseOverlayfs, "-o", opts, dir)
+	if out, fuseErr := cmd.CombinedOutput(); fuseErr != nil {
+		return fmt.Errorf("failed to mount overlayfs on %s: %w; also failed to mount fuse-overlayfs: %s: %v", dir, err, string(out), fuseErr)
+	}
+	return nil
+}
+
+// escape escapes the path for the mount options of overlayfs
+func escape(p string) string {
+	return strings.NewReplacer(`\`, `\\`, `:`, `\:`, `,`, `\,`).Replace(p)
+}
@@ -0,0 +1,98 @@
+package overlayfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+func TestTopLevelSubmounts(t *testing.T) {
+	testCases := []struct {
+		dir         string
+		mountpoints []string
+		expected    []string
+	}{
+		{
+			dir:         "/run",
+			mountpoints: []string{"/run"},
+			expected:    nil,
+		},
+		{
+			dir:         "/run",
+			mountpoints: []string{"/run", "/run/user/1001", "/run/user/1001/doc", "/run/lock", "/running"},
+			expected:    []string{"/run/lock", "/run/user/1001"},
+		},
+		{
+			dir:         "/run",
+			mountpoints: []string{"/run/a", "/run/a-b", "/run/a/c", "/run/a"},
+			expected:    []string{"/run/a", "/run/a-b"},
+		},
+	}
+	for _, tc := range testCases {
+		assert.DeepEqual(t, tc.expected, topLevelSubmounts(tc.dir, tc.mountpoints))
+	}
+}
+
+// TestCopyUpSubmounts mounts overlayfs in a new mount namespace, so it needs the root.
+func TestCopyUpSubmounts(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires the root")
+	}
+	dir := filepath.Join(t.TempDir(), "dir")
+	sub := filepath.Join(dir, "sub")
+	assert.NilError(t, os.MkdirAll(sub, 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("foo"), 0644))
+
+	unshareErrCh := make(chan error, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		// the thread is not reused, as it is not unlocked
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+			unshareErrCh <- err
+			return
+		}
+		close(unshareErrCh)
+		errCh <- func() error {
+			if err := unix.Mount("none", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+				return err
+			}
+			if err := unix.Mount("none", sub, "tmpfs", 0, ""); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(sub, "bar"), []byte("bar"), 0644); err != nil {
+				return err
+			}
+			copied, err := NewChildDriver(nil).CopyUp([]string{dir})
+			if err != nil {
+				return err
+			}
+			if len(copied) != 1 || copied[0] != dir {
+				return fmt.Errorf("unexpected copied directories: %v", copied)
+			}
+			// the tmpfs on sub is still visible
+			if b, err := os.ReadFile(filepath.Join(sub, "bar")); err != nil || string(b) != "bar" {
+				return fmt.Errorf("unexpected content of the submount: %q: %w", string(b), err)
+			}
+			// dir is writable without modifying the lower
+			if err := os.WriteFile(filepath.Join(dir, "foo"), []byte("baz"), 0644); err != nil {
+				return err
+			}
+			return nil
+		}()
+	}()
+	if err := <-unshareErrCh; err != nil {
+		t.Skipf("failed to create a mount namespace: %v", err)
+	}
+	assert.NilError(t, <-errCh)
+	b, err := os.ReadFile(filepath.Join(dir, "foo"))
+	assert.NilError(t, err)
+	assert.Equal(t, "foo", string(b))
+	_, err = os.Stat(filepath.Join(sub, "bar"))
+	assert.Assert(t, os.IsNotExist(err))
+}
@@ -0,0 +1,143 @@
+// Package events provides the lifecycle events of RootlessKit, streamed via `GET /v1/events`.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
+)
+
+type Type string
+
+const (
+	TypeNetworkConfigured = Type("network-configured")
+	TypePortAdded         = Type("port-added")
+	TypePortRemoved       = Type("port-removed")
+	TypeChildExited       = Type("child-exited")
+	TypeHelperExited      = Type("helper-exited") // e.g., slirp4netns exited unexpectedly
+	TypeTargetRestarted   = Type("target-restarted")
+	TypeShutdown          = Type("shutdown")
+	TypeFrozen            = Type("frozen")
+	TypeThawed            = Type("thawed")
+)
+
+// Event is the structure streamed via `GET /events` (since API v1.2.0)
+type Event struct {
+	Time time.Time `json:"time"`
+	Type Type      `json:"type"`
+	// Network is set for TypeNetworkConfigured
+	Network *api.NetworkDriverInfo `json:"network,omitempty"`
+	// Port is set for TypePortAdded and TypePortRemoved
+	Port *port.Status `json:"port,omitempty"`
+	// ExitCode is set for TypeChildExited and TypeTargetRestarted
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Signal is set for TypeChildExited, when the child was killed by a signal
+	Signal string `json:"signal,omitempty"`
+	// Helper is set for TypeHelperExited, e.g., "slirp4netns"
+	Helper string `json:"helper,omitempty"`
+	// Restarts is set for TypeTargetRestarted
+	Restarts int    `json:"restarts,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+const (
+	// historySize is the number of the events kept for Subscribe with since.
+	historySize = 256
+	// subscriberBuffer is the number of the events buffered per subscriber.
+	// Events are dropped when the subscriber is too slow.
+	subscriberBuffer = 64
+)
+
+// Bus distributes the events to the subscribers.
+// Bus is thread-safe.
+// The methods of a nil Bus are no-op.
+type Bus struct {
+	mu          sync.Mutex
+	history     []Event
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish publishes the event. The Time field is filled if empty.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.history = append(b.history, ev)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Drop the event for the slow subscriber
+		}
+	}
+}
+
+// Subscribe returns the past events newer than since, and a channel that receives the subsequent events.
+// The past events are not returned if since is zero.
+//
+// The channel is closed when cancel is called or when the Bus is closed.
+func (b *Bus) Subscribe(since time.Time) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	if b == nil {
+		close(ch)
+		return nil, ch, func() {}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var past []Event
+	if !since.IsZero() {
+		for _, ev := range b.history {
+			if ev.Time.After(since) {
+				past = append(past, ev)
+			}
+		}
+	}
+	if b.closed {
+		close(ch)
+		return past, ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return past, ch, cancel
+}
+
+// Close closes the channels of the subscribers.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
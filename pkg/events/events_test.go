@@ -0,0 +1,36 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	start := time.Now().Add(-time.Second)
+	b.Publish(Event{Type: TypeNetworkConfigured})
+
+	past, ch, cancel := b.Subscribe(time.Time{})
+	defer cancel()
+	assert.Equal(t, 0, len(past))
+
+	past2, ch2, cancel2 := b.Subscribe(start)
+	assert.Equal(t, 1, len(past2))
+	assert.Equal(t, TypeNetworkConfigured, past2[0].Type)
+	cancel2()
+	_, ok := <-ch2
+	assert.Equal(t, false, ok)
+
+	code := 0
+	b.Publish(Event{Type: TypeChildExited, ExitCode: &code})
+	ev := <-ch
+	assert.Equal(t, TypeChildExited, ev.Type)
+	assert.Equal(t, 0, *ev.ExitCode)
+	assert.Assert(t, !ev.Time.IsZero())
+
+	b.Close()
+	_, ok = <-ch
+	assert.Equal(t, false, ok)
+}
@@ -0,0 +1,116 @@
+// Package hook provides the lifecycle hooks that are executed at the phases of the parent/child handshake.
+//
+// A hook receives the JSON representation of State on stdin, like OCI hooks.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/version"
+)
+
+type Phase string
+
+const (
+	// PhaseIdmap hooks are executed in the parent, after the UID/GID map of the child is written.
+	PhaseIdmap = Phase("idmap")
+	// PhaseUserNS hooks are executed in the child, after the child gained the caps inside the user namespace.
+	PhaseUserNS = Phase("userns")
+	// PhaseNetwork hooks are executed in the child, after the network is configured.
+	PhaseNetwork = Phase("network")
+	// PhasePorts hooks are executed in the parent, after the ports are published.
+	PhasePorts = Phase("ports")
+	// PhaseExit hooks are executed in the parent, after the child exited.
+	PhaseExit = Phase("exit")
+)
+
+// Phases is the list of the phases, in the order of execution.
+var Phases = []Phase{PhaseIdmap, PhaseUserNS, PhaseNetwork, PhasePorts, PhaseExit}
+
+type Hook struct {
+	Phase Phase
+	Path  string // absolute path of the executable
+}
+
+func (h Hook) String() string {
+	return string(h.Phase) + ":" + h.Path
+}
+
+// Parse parses "<phase>:<path>".
+func Parse(s string) (Hook, error) {
+	var h Hook
+	phase, path, ok := strings.Cut(s, ":")
+	if !ok {
+		return h, fmt.Errorf("invalid hook %q, expected \"<phase>:<path>\"", s)
+	}
+	h.Phase = Phase(phase)
+	known := false
+	for _, p := range Phases {
+		if h.Phase == p {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return h, fmt.Errorf("invalid hook %q, unknown phase %q (known phases: %v)", s, phase, Phases)
+	}
+	if !filepath.IsAbs(path) {
+		return h, fmt.Errorf("invalid hook %q, path must be absolute", s)
+	}
+	h.Path = path
+	return h, nil
+}
+
+// State is passed to the hooks on stdin.
+type State struct {
+	Version  string `json:"version"` // Implementation version
+	Phase    Phase  `json:"phase"`
+	StateDir string `json:"stateDir"`
+	// ChildPID is the PID of the child, as seen from the parent.
+	// Set only for the phases executed in the parent.
+	ChildPID int `json:"childPID,omitempty"`
+	// DetachedNetNS is the path of the detached network namespace.
+	// Set only for the phases executed in the child, with --detach-netns.
+	DetachedNetNS string `json:"detachedNetNS,omitempty"`
+	// ExitCode is set only for PhaseExit.
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// Run runs the hooks of the phase, in the order of the slice.
+// Run fails on the first hook that fails.
+func Run(ctx context.Context, hooks []Hook, phase Phase, state State) error {
+	state.Version = version.Version
+	state.Phase = phase
+	var stdin []byte
+	for _, h := range hooks {
+		if h.Phase != phase {
+			continue
+		}
+		if stdin == nil {
+			var err error
+			stdin, err = json.Marshal(state)
+			if err != nil {
+				return err
+			}
+		}
+		logrus.Debugf("Running hook %s", h)
+		cmd := exec.CommandContext(ctx, h.Path)
+		cmd.Stdin = bytes.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("hook %s failed: %q: %w", h, string(out), err)
+		}
+		if len(out) > 0 {
+			logrus.Debugf("Hook %s: %s", h, string(out))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	h, err := Parse("network:/usr/local/bin/foo")
+	assert.NilError(t, err)
+	assert.Equal(t, PhaseNetwork, h.Phase)
+	assert.Equal(t, "/usr/local/bin/foo", h.Path)
+
+	for _, s := range []string{"", "network", "foo:/bin/true", "exit:true"} {
+		_, err = Parse(s)
+		assert.ErrorContains(t, err, "invalid hook", s)
+	}
+}
+
+func TestRun(t *testing.T) {
+	d := t.TempDir()
+	out := filepath.Join(d, "out")
+	script := filepath.Join(d, "hook.sh")
+	assert.NilError(t, os.WriteFile(script, []byte("#!/bin/sh\ncat > "+out+"\n"), 0755))
+	failing := filepath.Join(d, "failing.sh")
+	assert.NilError(t, os.WriteFile(failing, []byte("#!/bin/sh\necho oops\nexit 1\n"), 0755))
+
+	hooks := []Hook{
+		{Phase: PhaseExit, Path: script},
+		{Phase: PhasePorts, Path: failing},
+	}
+	code := 42
+	assert.NilError(t, Run(context.Background(), hooks, PhaseExit, State{StateDir: d, ChildPID: 4242, ExitCode: &code}))
+	b, err := os.ReadFile(out)
+	assert.NilError(t, err)
+	var st State
+	assert.NilError(t, json.Unmarshal(b, &st))
+	assert.Equal(t, PhaseExit, st.Phase)
+	assert.Equal(t, 4242, st.ChildPID)
+	assert.Equal(t, 42, *st.ExitCode)
+
+	err = Run(context.Background(), hooks, PhasePorts, State{StateDir: d})
+	assert.ErrorContains(t, err, "oops")
+}
@@ -0,0 +1,131 @@
+// Package landlock restricts the filesystem access of the current thread with Landlock.
+//
+// See https://docs.kernel.org/userspace-api/landlock.html
+package landlock
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// accessFSV1 is the filesystem access rights supported since ABI 1 (Linux 5.13)
+	accessFSV1 = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+	// accessFile is the access rights that can be granted for a non-directory file
+	accessFile = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+
+	// accessRO is the access rights granted for the read-only paths
+	accessRO = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+)
+
+// ABI returns the Landlock ABI version supported by the kernel.
+// ABI returns 0 when Landlock is not supported, or disabled.
+func ABI() int {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(v)
+}
+
+// handledAccessFS returns the filesystem access rights handled with the ABI version.
+func handledAccessFS(abi int) uint64 {
+	access := uint64(accessFSV1)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// Restrict restricts the filesystem access of the current thread to ro and rw.
+// The restriction is inherited to the processes executed from the current thread.
+//
+// Restrict returns the ABI version that was enforced.
+// Restrict returns 0 without an error when Landlock is not supported by the kernel.
+//
+// Restrict requires CAP_SYS_ADMIN, or PR_SET_NO_NEW_PRIVS to be set.
+// The caller should lock the OS thread.
+func Restrict(ro, rw []string) (int, error) {
+	abi := ABI()
+	if abi == 0 {
+		return 0, nil
+	}
+	handled := handledAccessFS(abi)
+	attr := unix.LandlockRulesetAttr{
+		Access_fs: handled,
+	}
+	// The size of the attr is limited to Access_fs, as Access_net and Scoped are not supported by ABI < 4 and ABI < 6
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)),
+		unsafe.Sizeof(attr.Access_fs), 0)
+	if errno != 0 {
+		return 0, fmt.Errorf("failed to create a Landlock ruleset: %w", errno)
+	}
+	rulesetFD := int(fd)
+	defer unix.Close(rulesetFD)
+	for _, p := range ro {
+		if err := addPathRule(rulesetFD, p, accessRO&handled); err != nil {
+			return 0, err
+		}
+	}
+	for _, p := range rw {
+		if err := addPathRule(rulesetFD, p, handled); err != nil {
+			return 0, err
+		}
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFD), 0, 0); errno != 0 {
+		return 0, fmt.Errorf("failed to enforce the Landlock ruleset: %w", errno)
+	}
+	return abi, nil
+}
+
+func addPathRule(rulesetFD int, p string, access uint64) error {
+	fd, err := unix.Open(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: p, Err: err}
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return &os.PathError{Op: "fstat", Path: p, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= accessFile
+	}
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFD), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add a Landlock rule for %q: %w", p, errno)
+	}
+	return nil
+}
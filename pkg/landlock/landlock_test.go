@@ -0,0 +1,58 @@
+package landlock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+func TestRestrict(t *testing.T) {
+	if ABI() == 0 {
+		t.Skip("Landlock is not supported")
+	}
+	ro, rw, other := t.TempDir(), t.TempDir(), t.TempDir()
+	roFile := filepath.Join(ro, "foo")
+	assert.NilError(t, os.WriteFile(roFile, []byte("foo"), 0644))
+	otherFile := filepath.Join(other, "foo")
+	assert.NilError(t, os.WriteFile(otherFile, []byte("foo"), 0644))
+
+	errCh := make(chan error)
+	go func() {
+		// The thread is not unlocked, so that it is terminated with the restriction on exiting the goroutine
+		runtime.LockOSThread()
+		errCh <- func() error {
+			if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+				return err
+			}
+			abi, err := Restrict([]string{ro}, []string{rw})
+			if err != nil {
+				return err
+			}
+			if abi != ABI() {
+				return errors.New("unexpected ABI")
+			}
+			if _, err := os.ReadFile(roFile); err != nil {
+				return err
+			}
+			if err := os.WriteFile(roFile, []byte("bar"), 0644); !errors.Is(err, unix.EACCES) {
+				return errors.New("expected EACCES for writing the read-only file")
+			}
+			if err := os.WriteFile(filepath.Join(rw, "foo"), []byte("bar"), 0644); err != nil {
+				return err
+			}
+			if _, err := os.ReadFile(otherFile); !errors.Is(err, unix.EACCES) {
+				return errors.New("expected EACCES for reading the other file")
+			}
+			return nil
+		}()
+	}()
+	assert.NilError(t, <-errCh)
+	// The other threads are not restricted
+	_, err := os.ReadFile(otherFile)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,176 @@
+// Package logfile provides a size-based rotating log file that can be followed.
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	DefaultMaxSize    = 10 * 1024 * 1024
+	DefaultMaxBackups = 3
+	// subscriberBuffer is the number of chunks buffered per subscriber.
+	// Chunks are dropped when the subscriber is too slow.
+	subscriberBuffer = 1024
+)
+
+// Chunk is a chunk of written data.
+type Chunk struct {
+	Stderr bool
+	Data   []byte
+}
+
+// Writer writes the log into a file, and rotates the file when the size exceeds MaxSize.
+// The rotated files are renamed to "<path>.1", "<path>.2", ... "<path>.<MaxBackups>".
+//
+// Writer is thread-safe.
+type Writer struct {
+	path        string
+	maxSize     int64
+	maxBackups  int
+	mu          sync.Mutex
+	f           *os.File
+	size        int64
+	subscribers map[chan Chunk]struct{}
+	closed      bool
+}
+
+// New creates a new Writer. Existing file is truncated.
+func New(path string, maxSize int64, maxBackups int) (*Writer, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid max size %d", maxSize)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		path:        path,
+		maxSize:     maxSize,
+		maxBackups:  maxBackups,
+		f:           f,
+		subscribers: make(map[chan Chunk]struct{}),
+	}
+	return w, nil
+}
+
+// Stdout returns an io.Writer for stdout.
+func (w *Writer) Stdout() io.Writer {
+	return &streamWriter{w: w, stderr: false}
+}
+
+// Stderr returns an io.Writer for stderr.
+func (w *Writer) Stderr() io.Writer {
+	return &streamWriter{w: w, stderr: true}
+}
+
+type streamWriter struct {
+	w      *Writer
+	stderr bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	return sw.w.write(p, sw.stderr)
+}
+
+func (w *Writer) write(p []byte, stderr bool) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	if n > 0 {
+		c := Chunk{Stderr: stderr, Data: append([]byte(nil), p[:n]...)}
+		for ch := range w.subscribers {
+			select {
+			case ch <- c:
+			default:
+				// Drop the chunk for the slow subscriber.
+				// NOTE: logrus may write to this Writer, so do not log here.
+			}
+		}
+	}
+	return n, err
+}
+
+// rotate must be called with w.mu held.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	for i := w.maxBackups; i > 0; i-- {
+		src := w.path
+		if i > 1 {
+			src = w.path + "." + strconv.Itoa(i-1)
+		}
+		dst := w.path + "." + strconv.Itoa(i)
+		if err := os.Rename(src, dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.size = 0
+	return nil
+}
+
+// Follow returns the current content of the log file (excluding the rotated ones),
+// and a channel that receives the subsequent writes.
+//
+// The channel is closed when cancel is called or when the Writer is closed.
+func (w *Writer) Follow() ([]byte, <-chan Chunk, func(), error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, nil, nil, os.ErrClosed
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+	current, err := io.ReadAll(io.LimitReader(f, w.size))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ch := make(chan Chunk, subscriberBuffer)
+	w.subscribers[ch] = struct{}{}
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[ch]; ok {
+			delete(w.subscribers, ch)
+			close(ch)
+		}
+	}
+	return current, ch, cancel, nil
+}
+
+// Close closes the file and the channels returned by Follow.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	for ch := range w.subscribers {
+		delete(w.subscribers, ch)
+		close(ch)
+	}
+	return w.f.Close()
+}
@@ -0,0 +1,50 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRotate(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "log")
+	w, err := New(p, 4, 2)
+	assert.NilError(t, err)
+	defer w.Close()
+	for _, s := range []string{"aaa", "bbb", "ccc", "ddd"} {
+		_, err = w.Stdout().Write([]byte(s))
+		assert.NilError(t, err)
+	}
+	for suffix, expected := range map[string]string{"": "ddd", ".1": "ccc", ".2": "bbb"} {
+		b, err := os.ReadFile(p + suffix)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+	_, err = os.Stat(p + ".3")
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestFollow(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "log")
+	w, err := New(p, DefaultMaxSize, DefaultMaxBackups)
+	assert.NilError(t, err)
+	_, err = w.Stdout().Write([]byte("foo\n"))
+	assert.NilError(t, err)
+
+	current, ch, cancel, err := w.Follow()
+	assert.NilError(t, err)
+	defer cancel()
+	assert.Equal(t, "foo\n", string(current))
+
+	_, err = w.Stderr().Write([]byte("bar\n"))
+	assert.NilError(t, err)
+	c := <-ch
+	assert.Equal(t, true, c.Stderr)
+	assert.Equal(t, "bar\n", string(c.Data))
+
+	assert.NilError(t, w.Close())
+	_, ok := <-ch
+	assert.Equal(t, false, ok)
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -26,27 +27,30 @@ func MarshalToWriter(w io.Writer, x interface{}) (int, error) {
 	return w.Write(append(h, b...))
 }
 
+// UnmarshalFromReader reads a message from r.
+// io.EOF is returned only when r is closed before reading the header.
 func UnmarshalFromReader(r io.Reader, x interface{}) (int, error) {
 	hdr := make([]byte, 4)
-	n, err := r.Read(hdr)
+	// ReadFull is needed, as a message may not be read at once from a pipe
+	n, err := io.ReadFull(r, hdr)
 	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, fmt.Errorf("read %d bytes, expected 4 bytes", n)
+		}
 		return n, err
 	}
-	if n != 4 {
-		return n, fmt.Errorf("read %d bytes, expected 4 bytes", n)
-	}
 	bLen := binary.LittleEndian.Uint32(hdr)
 	if bLen > maxLength || bLen < 1 {
 		return n, fmt.Errorf("bad message length: %d (max: %d)", bLen, maxLength)
 	}
 	b := make([]byte, bLen)
-	n, err = r.Read(b)
+	n, err = io.ReadFull(r, b)
 	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return 4 + n, fmt.Errorf("read %d bytes, expected %d bytes", n, bLen)
+		}
 		return 4 + n, err
 	}
-	if n != int(bLen) {
-		return 4 + n, fmt.Errorf("read %d bytes, expected %d bytes", n, bLen)
-	}
 	return 4 + n, json.Unmarshal(b, x)
 }
 
@@ -0,0 +1,17 @@
+package messages
+
+// Operations of ParentRequest.
+const (
+	// OpSysctl gets or sets a sysctl in the namespaces of the child.
+	// The arguments are SysctlArgs, and the result is SysctlResult.
+	OpSysctl = "sysctl"
+)
+
+type SysctlArgs struct {
+	Key   string  // e.g., "net.ipv4.ping_group_range"
+	Value *string // nil for getting the value
+}
+
+type SysctlResult struct {
+	Value string // the current value
+}
@@ -1,6 +1,7 @@
 package messages
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -32,6 +33,12 @@ func (m *Message) FulfillName() error {
 }
 
 // U is a union.
+//
+// The fields of the members are flattened in JSON, so the field names must be unique across the members.
+// New members can be added without breaking older peers:
+//   - A message that the peer may not understand must be sent only when the peer advertised
+//     the name of the message in the capabilities of the hello message.
+//   - Unknown messages are skipped by WaitFor.
 type U struct {
 	*ParentHello
 	*ChildHello
@@ -39,12 +46,27 @@ type U struct {
 	*ChildInitUserNSCompleted
 	*ParentInitNetworkDriverCompleted
 	*ParentInitPortDriverCompleted
+	*ChildTargetRestarted
+	*ChildLandlockApplied
+	*ChildError
+	*ParentRequest
+	*ChildResponse
 }
 
 type ParentHello struct {
+	// The fields are empty for the parent of RootlessKit prior to v3.1.0,
+	// which is treated as ProtocolVersion 0.
+	ParentProtocolVersion    int
+	ParentMinProtocolVersion int // the minimum protocol version of the child
+	ParentCapabilities       []string
 }
 
 type ChildHello struct {
+	// The fields are empty for the child of RootlessKit prior to v3.1.0,
+	// which is treated as ProtocolVersion 0.
+	ChildProtocolVersion    int
+	ChildMinProtocolVersion int // the minimum protocol version of the parent
+	ChildCapabilities       []string
 }
 
 type ParentInitIdmapCompleted struct {
@@ -71,6 +93,43 @@ type ParentInitPortDriverCompleted struct {
 	PortDriverOpaque map[string]string
 }
 
+// ChildTargetRestarted is sent after the initialization, when the target command is restarted
+// with the restart policy.
+type ChildTargetRestarted struct {
+	Restarts int // the total number of the restarts
+	ExitCode int // the exit code of the previous run, or -1 if unknown
+}
+
+// ChildLandlockApplied is sent after the initialization, when the exec helper enforced Landlock
+// for the target command.
+type ChildLandlockApplied struct {
+	LandlockABI int // the enforced ABI version, or 0 if Landlock is not supported by the kernel
+}
+
+// ChildError is sent before the child exits on an error.
+// The field names must not conflict with the fields of the other messages.
+type ChildError struct {
+	Phase        string // e.g., "copy-up"
+	ErrorMessage string
+	Errno        int // 0 if unknown
+}
+
+// ParentRequest is sent after the initialization, when the child has the capability.
+// The child replies ChildResponse with the same ID.
+type ParentRequest struct {
+	RequestID   uint64
+	RequestOp   string // e.g., OpSysctl
+	RequestArgs json.RawMessage
+}
+
+// ChildResponse is sent for ParentRequest, when the parent has the capability.
+type ChildResponse struct {
+	ResponseID     uint64
+	ResponseResult json.RawMessage `json:",omitempty"`
+	ResponseError  string          `json:",omitempty"`
+	ResponseErrno  int             `json:",omitempty"`
+}
+
 func Send(w io.Writer, m *Message) error {
 	if m.Name == "" {
 		if err := m.FulfillName(); err != nil {
@@ -96,15 +155,29 @@ func Recv(r io.Reader) (*Message, error) {
 	return &m, nil
 }
 
+// WaitFor receives the message with the name.
+// Unknown messages sent from newer peers are skipped.
 func WaitFor(r io.Reader, name string) (*Message, error) {
-	msg, err := Recv(r)
-	if err != nil {
-		return nil, err
-	}
-	if msg.Name != name {
-		return nil, fmt.Errorf("expected %q, got %+v", name, msg)
+	for {
+		msg, err := Recv(r)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Name != name {
+			if !Known(msg.Name) {
+				logrus.Debugf("Skipping unknown message %q", msg.Name)
+				continue
+			}
+			return nil, fmt.Errorf("expected %q, got %+v", name, msg)
+		}
+		return msg, nil
 	}
-	return msg, nil
+}
+
+// Known returns whether the message name is a member of U.
+func Known(name string) bool {
+	_, ok := reflect.TypeOf(U{}).FieldByName(name)
+	return ok
 }
 
 func Name(x interface{}) string {
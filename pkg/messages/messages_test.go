@@ -0,0 +1,53 @@
+package messages
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestUniqueFieldNames verifies that the field names of the members of U are unique,
+// as they are flattened in JSON.
+func TestUniqueFieldNames(t *testing.T) {
+	seen := make(map[string]string)
+	uT := reflect.TypeOf(U{})
+	for i := 0; i < uT.NumField(); i++ {
+		member := uT.Field(i)
+		mT := member.Type.Elem()
+		for j := 0; j < mT.NumField(); j++ {
+			name := mT.Field(j).Name
+			if other, ok := seen[name]; ok {
+				t.Errorf("field %q is defined in both %s and %s", name, other, member.Name)
+			}
+			seen[name] = member.Name
+		}
+	}
+}
+
+func TestWaitForSkipsUnknown(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NilError(t, Send(&buf, &Message{Name: "MessageFromTheFuture"}))
+	assert.NilError(t, Send(&buf, &Message{U: U{ParentHello: &ParentHello{ParentProtocolVersion: ProtocolVersion}}}))
+	msg, err := WaitFor(&buf, Name(ParentHello{}))
+	assert.NilError(t, err)
+	assert.Equal(t, ProtocolVersion, msg.U.ParentHello.ParentProtocolVersion)
+}
+
+func TestNewPeer(t *testing.T) {
+	// RootlessKit prior to v3.1.0
+	p, err := NewPeer("parent", 0, 0, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, false, p.Can(Name(ChildError{})))
+
+	p, err = NewPeer("parent", ProtocolVersion, MinProtocolVersion, ParentCapabilities)
+	assert.NilError(t, err)
+	assert.Equal(t, true, p.Can(Name(ChildError{})))
+
+	_, err = NewPeer("parent", ProtocolVersion+1, ProtocolVersion+1, nil)
+	assert.ErrorContains(t, err, "incompatible RootlessKit binaries")
+
+	var nilPeer *Peer
+	assert.Equal(t, false, nilPeer.Can(Name(ChildError{})))
+}
@@ -0,0 +1,69 @@
+package messages
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// ProtocolVersion is the version of the parent-child protocol.
+	// Incremented when a message is added or changed.
+	//
+	//   - 0: RootlessKit prior to v3.1.0
+	//   - 1: Added the protocol versions and the capabilities to the hello messages.
+	//        Added ChildTargetRestarted, ChildError, ParentRequest, and ChildResponse.
+	//   - 2: Added ChildLandlockApplied.
+	ProtocolVersion = 2
+
+	// MinProtocolVersion is the minimum protocol version of the peer.
+	// Increased only when an incompatible change is made.
+	MinProtocolVersion = 0
+)
+
+// The capabilities are the names of the optional messages that the peer can receive.
+// The optional messages must not be sent to a peer that does not have the capability.
+var (
+	// ParentCapabilities are sent in ParentHello.
+	ParentCapabilities = []string{
+		Name(ChildTargetRestarted{}),
+		Name(ChildLandlockApplied{}),
+		Name(ChildError{}),
+		Name(ChildResponse{}),
+	}
+	// ChildCapabilities are sent in ChildHello.
+	ChildCapabilities = []string{
+		Name(ParentRequest{}),
+	}
+)
+
+// Peer is the negotiated information of the peer.
+type Peer struct {
+	ProtocolVersion int
+	Capabilities    []string
+}
+
+// NewPeer checks the protocol version of the peer, and returns the negotiated information of the peer.
+// peerName is like "parent".
+func NewPeer(peerName string, version, minVersion int, caps []string) (*Peer, error) {
+	if version < MinProtocolVersion {
+		return nil, fmt.Errorf("incompatible RootlessKit binaries: the %s speaks the protocol version %d, but the version %d or later is required (hint: make sure that the parent and the child are executed from the same RootlessKit binary)",
+			peerName, version, MinProtocolVersion)
+	}
+	if minVersion > ProtocolVersion {
+		return nil, fmt.Errorf("incompatible RootlessKit binaries: the %s requires the protocol version %d or later, but this binary speaks the version %d (hint: make sure that the parent and the child are executed from the same RootlessKit binary)",
+			peerName, minVersion, ProtocolVersion)
+	}
+	return &Peer{
+		ProtocolVersion: version,
+		Capabilities:    caps,
+	}, nil
+}
+
+// Can returns whether the peer can receive the message.
+// Can returns false for a nil Peer.
+func (p *Peer) Can(name string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Capabilities, name)
+}
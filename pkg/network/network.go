@@ -17,6 +17,13 @@ type ParentDriver interface {
 	ConfigureNetwork(childPID int, stateDir, detachedNetNSPath string) (netmsg *messages.ParentInitNetworkDriverCompleted, cleanup func() error, err error)
 }
 
+// HelperWatcher is optionally implemented by ParentDriver that runs a helper process, such as slirp4netns.
+type HelperWatcher interface {
+	// HelperExited returns a channel that is closed when the helper process exited.
+	// HelperExited returns nil if the helper process is not running.
+	HelperExited() <-chan struct{}
+}
+
 type ChildDriverInfo struct {
 	ConfiguresInterface bool // Driver configures own namespace interface
 }
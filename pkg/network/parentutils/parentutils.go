@@ -3,8 +3,11 @@ package parentutils
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"strconv"
 
+	"golang.org/x/sys/unix"
+
 	"github.com/rootless-containers/rootlesskit/v3/pkg/common"
 )
 
@@ -30,3 +33,54 @@ func nsenter(childPID int, childNetNsPath string, cmd []string) []string {
 	fullCmd = append(fullCmd, cmd...)
 	return fullCmd
 }
+
+// ProcessWatcher watches a helper process in background.
+type ProcessWatcher struct {
+	done chan struct{}
+	err  error
+}
+
+// WatchCmd calls cmd.Wait in background.
+// cmd must not be waited elsewhere.
+func WatchCmd(cmd *exec.Cmd) *ProcessWatcher {
+	w := &ProcessWatcher{done: make(chan struct{})}
+	go func() {
+		w.err = cmd.Wait()
+		close(w.done)
+	}()
+	return w
+}
+
+// WatchPID watches a process that is not a child of the current process, using pidfd.
+func WatchPID(pid int) (*ProcessWatcher, error) {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pidfd for %d: %w", pid, err)
+	}
+	w := &ProcessWatcher{done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		defer unix.Close(fd)
+		// pidfd becomes readable when the process exits
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for {
+			_, err := unix.Poll(fds, -1)
+			if err != unix.EINTR {
+				w.err = err
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Done is closed when the process exited.
+func (w *ProcessWatcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err blocks until Done is closed, and returns the error of cmd.Wait for WatchCmd.
+func (w *ProcessWatcher) Err() error {
+	<-w.done
+	return w.err
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/sirupsen/logrus"
@@ -17,6 +20,7 @@ import (
 	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network/iputils"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/network/parentutils"
 )
 
 type Features struct {
@@ -104,10 +108,20 @@ type parentDriver struct {
 	implicitPortForwarding bool
 	info                   func() *api.NetworkDriverInfo
 	feat                   *Features
+	watcher                *parentutils.ProcessWatcher
 }
 
 const DriverName = "pasta"
 
+func (d *parentDriver) HelperExited() <-chan struct{} {
+	d.infoMu.RLock()
+	defer d.infoMu.RUnlock()
+	if d.watcher == nil {
+		return nil
+	}
+	return d.watcher.Done()
+}
+
 func (d *parentDriver) Info(ctx context.Context) (*api.NetworkDriverInfo, error) {
 	d.infoMu.RLock()
 	infoFn := d.info
@@ -143,8 +157,10 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 		return nil, common.Seq(cleanups), err
 	}
 
+	pidFile := filepath.Join(stateDir, "pasta.pid")
 	opts := []string{
 		"--stderr",
+		"--pid=" + pidFile,
 		"--ns-ifname=" + d.ifname,
 		"--mtu=" + strconv.Itoa(d.mtu),
 		"--config-net",
@@ -204,6 +220,16 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 		return nil, common.Seq(cleanups), fmt.Errorf("executing %v: %w", cmd, err)
 	}
 
+	// pasta daemonizes itself, so the PID is read from the PID file
+	var watcher *parentutils.ProcessWatcher
+	if pidB, err := os.ReadFile(pidFile); err != nil {
+		logrus.WithError(err).Warn("failed to read the PID of pasta")
+	} else if pid, err := strconv.Atoi(strings.TrimSpace(string(pidB))); err != nil {
+		logrus.WithError(err).Warnf("failed to parse the PID of pasta %q", string(pidB))
+	} else if watcher, err = parentutils.WatchPID(pid); err != nil {
+		logrus.WithError(err).Warn("failed to watch pasta")
+	}
+
 	netmsg := messages.ParentInitNetworkDriverCompleted{
 		Dev: tap,
 		MTU: d.mtu,
@@ -214,6 +240,7 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 	netmsg.DNS = []string{dns.String()}
 
 	d.infoMu.Lock()
+	d.watcher = watcher
 	d.info = func() *api.NetworkDriverInfo {
 		return &api.NetworkDriverInfo{
 			Driver:         DriverName,
@@ -155,10 +155,20 @@ type parentDriver struct {
 	ifname              string
 	infoMu              sync.RWMutex
 	info                func() *api.NetworkDriverInfo
+	watcher             *parentutils.ProcessWatcher
 }
 
 const DriverName = "slirp4netns"
 
+func (d *parentDriver) HelperExited() <-chan struct{} {
+	d.infoMu.RLock()
+	defer d.infoMu.RUnlock()
+	if d.watcher == nil {
+		return nil
+	}
+	return d.watcher.Done()
+}
+
 func (d *parentDriver) Info(ctx context.Context) (*api.NetworkDriverInfo, error) {
 	d.infoMu.RLock()
 	infoFn := d.info
@@ -228,12 +238,18 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 		Pdeathsig: syscall.SIGKILL,
 	}
 	cmd.ExtraFiles = append(cmd.ExtraFiles, readyW)
+	var watcher *parentutils.ProcessWatcher
 	cleanups = append(cleanups, func() error {
 		logrus.Debug("killing slirp4netns")
 		if cmd.Process != nil {
 			_ = cmd.Process.Kill()
 		}
-		wErr := cmd.Wait()
+		var wErr error
+		if watcher != nil {
+			wErr = watcher.Err()
+		} else {
+			wErr = cmd.Wait()
+		}
 		logrus.Debugf("killed slirp4netns: %v", wErr)
 		return nil
 	})
@@ -244,6 +260,8 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 	if err := waitForReadyFD(cmd.Process.Pid, readyR); err != nil {
 		return nil, common.Seq(cleanups), fmt.Errorf("waiting for ready fd (%v): %w", cmd, err)
 	}
+	// waitForReadyFD calls wait4 with WNOHANG, so the watcher has to be started after that
+	watcher = parentutils.WatchCmd(cmd)
 	netmsg := messages.ParentInitNetworkDriverCompleted{
 		Dev: tap,
 		DNS: make([]string, 0, 2),
@@ -286,6 +304,7 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 	}
 
 	d.infoMu.Lock()
+	d.watcher = watcher
 	d.info = func() *api.NetworkDriverInfo {
 		return &api.NetworkDriverInfo{
 			Driver:         DriverName,
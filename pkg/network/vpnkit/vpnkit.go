@@ -28,6 +28,7 @@ import (
 	"github.com/rootless-containers/rootlesskit/v3/pkg/common"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/network/parentutils"
 )
 
 func NewParentDriver(binary string, mtu int, ifname string, disableHostLoopback bool) network.ParentDriver {
@@ -69,6 +70,16 @@ type parentDriver struct {
 	disableHostLoopback bool
 	infoMu              sync.RWMutex
 	info                func() *api.NetworkDriverInfo
+	watcher             *parentutils.ProcessWatcher
+}
+
+func (d *parentDriver) HelperExited() <-chan struct{} {
+	d.infoMu.RLock()
+	defer d.infoMu.RUnlock()
+	if d.watcher == nil {
+		return nil
+	}
+	return d.watcher.Done()
 }
 
 func (d *parentDriver) Info(ctx context.Context) (*api.NetworkDriverInfo, error) {
@@ -99,16 +110,20 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 	vpnkitCmd.SysProcAttr = &syscall.SysProcAttr{
 		Pdeathsig: syscall.SIGKILL,
 	}
+	var watcher *parentutils.ProcessWatcher
 	cleanups = append(cleanups, func() error {
 		logrus.Debug("killing vpnkit")
 		vpnkitCancel()
-		wErr := vpnkitCmd.Wait()
-		logrus.Debugf("killed vpnkit: %v", wErr)
+		if watcher != nil {
+			wErr := watcher.Err()
+			logrus.Debugf("killed vpnkit: %v", wErr)
+		}
 		return nil
 	})
 	if err := vpnkitCmd.Start(); err != nil {
 		return nil, common.Seq(cleanups), fmt.Errorf("executing %v: %w", vpnkitCmd, err)
 	}
+	watcher = parentutils.WatchCmd(vpnkitCmd)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	cleanups = append(cleanups, func() error { cancel(); return nil })
 	vmnet, err := waitForVPNKit(ctx, vpnkitSocket)
@@ -139,6 +154,7 @@ func (d *parentDriver) ConfigureNetwork(childPID int, stateDir, detachedNetNSPat
 		},
 	}
 	d.infoMu.Lock()
+	d.watcher = watcher
 	d.info = func() *api.NetworkDriverInfo {
 		return &api.NetworkDriverInfo{
 			Driver:         DriverName,
@@ -0,0 +1,103 @@
+// Package nsenter constructs nsenter(1) commands for entering the namespaces of the RootlessKit child.
+package nsenter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+type Opt struct {
+	TargetPID int // needs to be set
+	// NetNSPath is the path of the detached netns, relative to the root of the parent.
+	// Set only for the detach-netns mode.
+	NetNSPath  string
+	WorkingDir string
+}
+
+// namespaces are listed in the order of nsenter(1).
+var namespaces = []struct {
+	name string
+	flag string
+}{
+	{"user", "--user"},
+	{"mnt", "--mount"},
+	{"net", "--net"},
+	{"pid", "--pid"},
+	{"uts", "--uts"},
+	{"ipc", "--ipc"},
+	{"cgroup", "--cgroup"},
+}
+
+func statNS(pid int, name string) (uint64, error) {
+	f := fmt.Sprintf("/proc/%d/ns/%s", pid, name)
+	st, err := os.Stat(f)
+	if err != nil {
+		return 0, err
+	}
+	stSys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("%T is not *syscall.Stat_t", st.Sys())
+	}
+	return stSys.Ino, nil
+}
+
+// Args returns the arguments for nsenter(1), without the "nsenter" itself.
+//
+// The namespaces that are shared with the current process are skipped.
+func Args(opt Opt) ([]string, error) {
+	if opt.TargetPID <= 0 {
+		return nil, errors.New("target PID is not set")
+	}
+	args := []string{"--target", strconv.Itoa(opt.TargetPID), "--preserve-credentials"}
+	for _, ns := range namespaces {
+		if ns.name == "net" && opt.NetNSPath != "" {
+			args = append(args, ns.flag+"="+opt.NetNSPath)
+			continue
+		}
+		target, err := statNS(opt.TargetPID, ns.name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// e.g., cgroup ns on old kernels
+				continue
+			}
+			return nil, err
+		}
+		self, err := statNS(os.Getpid(), ns.name)
+		if err != nil {
+			return nil, err
+		}
+		if target == self {
+			continue
+		}
+		args = append(args, ns.flag)
+	}
+	if opt.WorkingDir != "" {
+		args = append(args, "--wd="+opt.WorkingDir)
+	}
+	return args, nil
+}
+
+// Command returns *exec.Cmd for executing argv in the namespaces of opt.TargetPID.
+func Command(opt Opt, argv []string) (*exec.Cmd, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("no command specified")
+	}
+	nsenter, err := exec.LookPath("nsenter")
+	if err != nil {
+		return nil, fmt.Errorf("nsenter(1) is needed for executing commands in the namespaces: %w", err)
+	}
+	args, err := Args(opt)
+	if err != nil {
+		return nil, err
+	}
+	args = append(append(args, "--"), argv...)
+	cmd := exec.Command(nsenter, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+	}
+	return cmd, nil
+}
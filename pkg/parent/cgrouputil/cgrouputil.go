@@ -40,11 +40,13 @@ func EvacuateCgroup2(evac string) error {
 		return nil
 	}
 
-	newGroup := filepath.Join(oldGroup, evac)
-
-	oldPath := filepath.Join(mountpoint, oldGroup)
-	newPath := filepath.Join(mountpoint, newGroup)
+	return EvacuateGroup(filepath.Join(mountpoint, oldGroup), evac)
+}
 
+// EvacuateGroup evacuates the processes in the cgroup directory oldPath, e.g. "/sys/fs/cgroup/foo",
+// to the evac subgroup, in the same way as EvacuateCgroup2 does for the group of the current process.
+func EvacuateGroup(oldPath, evac string) error {
+	newPath := filepath.Join(oldPath, evac)
 	if err := os.MkdirAll(newPath, 0755); err != nil {
 		return err
 	}
@@ -59,7 +61,7 @@ func EvacuateCgroup2(evac string) error {
 			continue
 		}
 		if err := os.WriteFile(filepath.Join(newPath, "cgroup.procs"), []byte(pidStr), 0644); err != nil {
-			logrus.WithError(err).Warnf("failed to move process %s to cgroup %q", pidStr, newGroup)
+			logrus.WithError(err).Warnf("failed to move process %s to cgroup %q", pidStr, newPath)
 		}
 	}
 
@@ -78,6 +80,24 @@ func EvacuateCgroup2(evac string) error {
 	return nil
 }
 
+// CreateSubgroup creates the name subgroup of the group of the current process, and returns the directory,
+// e.g. "/sys/fs/cgroup/foo/name".
+// CreateSubgroup has to be called before EvacuateCgroup2, as EvacuateCgroup2 moves the current process.
+func CreateSubgroup(name string) (string, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("unexpected group name %q", name)
+	}
+	dir := Path(os.Getpid())
+	if dir == "" {
+		return "", errors.New("process is not running with cgroup2")
+	}
+	dir = filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 func findCgroup2Mountpoint() string {
 	f := mountinfoFSTypeFilter("cgroup2")
 	mounts, err := mountinfo.GetMounts(f)
@@ -0,0 +1,24 @@
+package cgrouputil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEvacuateGroup(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("10\n20\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("memory pids\n"), 0644))
+	assert.NilError(t, EvacuateGroup(dir, "evac"))
+
+	// the fake cgroup.procs and cgroup.subtree_control are overwritten on each write
+	b, err := os.ReadFile(filepath.Join(dir, "evac", "cgroup.procs"))
+	assert.NilError(t, err)
+	assert.Equal(t, "20", string(b))
+	b, err = os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	assert.NilError(t, err)
+	assert.Equal(t, "+pids", string(b))
+}
@@ -0,0 +1,58 @@
+package cgrouputil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Freeze freezes (or thaws, when frozen is false) the processes in the cgroup directory,
+// and waits for "cgroup.events" to report the state.
+// Freeze requires Linux 5.2 or later.
+func Freeze(ctx context.Context, dir string, frozen bool) error {
+	v := "0"
+	if frozen {
+		v = "1"
+	}
+	p := filepath.Join(dir, "cgroup.freeze")
+	if err := writeFile(p, v); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("cgroup freezer is not supported (needs Linux 5.2 or later): %w", err)
+		}
+		return fmt.Errorf("failed to write %q to %q: %w", v, p, err)
+	}
+	// cgroup.events is polled for simplicity, instead of being watched with inotify
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		cur, err := Frozen(dir)
+		if err != nil {
+			return err
+		}
+		if cur == frozen {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %q to report frozen=%s: %w", dir, v, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Frozen returns whether the cgroup directory is frozen, by reading "cgroup.events".
+func Frozen(dir string) (bool, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.events"))
+	if err != nil {
+		return false, err
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		if k, v, ok := strings.Cut(l, " "); ok && k == "frozen" {
+			return strings.TrimSpace(v) == "1", nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,32 @@
+package cgrouputil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte("0\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte("populated 1\nfrozen 1\n"), 0644))
+
+	frozen, err := Frozen(dir)
+	assert.NilError(t, err)
+	assert.Check(t, frozen)
+	assert.NilError(t, Freeze(context.Background(), dir, true))
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	assert.NilError(t, err)
+	assert.Equal(t, "1", string(b))
+
+	// cgroup.events is not updated by the fake cgroup
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, Freeze(ctx, dir, false), "timed out")
+
+	assert.ErrorContains(t, Freeze(context.Background(), t.TempDir(), true), "not supported")
+}
@@ -0,0 +1,195 @@
+package cgrouputil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+)
+
+// Limits is the resource limits written to the cgroup.
+// Empty values are not written.
+type Limits struct {
+	MemoryMax string // "memory.max", e.g. "1073741824" or "max"
+	CPUMax    string // "cpu.max", e.g. "50000 100000" or "max"
+	PidsMax   string // "pids.max", e.g. "1000" or "max"
+	IOWeight  string // "io.weight", e.g. "default 100"
+}
+
+// NewLimits validates the limits and returns Limits.
+//
+// memoryMax is bytes with an optional "K", "M", "G", or "T" suffix (base 1024), or "max".
+// cpuMax is "$MAX [$PERIOD]" in microseconds, or "max".
+// pidsMax is the number of the processes, or "max".
+// ioWeight is in the range of [1, 10000]. 0 for not setting the weight.
+//
+// NewLimits returns nil when no limit is specified.
+func NewLimits(memoryMax, cpuMax, pidsMax string, ioWeight uint64) (*Limits, error) {
+	if memoryMax == "" && cpuMax == "" && pidsMax == "" && ioWeight == 0 {
+		return nil, nil
+	}
+	var l Limits
+	if memoryMax != "" {
+		v, err := parseBytes(memoryMax)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory max %q: %w", memoryMax, err)
+		}
+		l.MemoryMax = v
+	}
+	if cpuMax != "" {
+		fields := strings.Fields(cpuMax)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid cpu max %q, expected \"$MAX [$PERIOD]\"", cpuMax)
+		}
+		for i, f := range fields {
+			if i == 0 && f == "max" {
+				continue
+			}
+			if n, err := strconv.ParseUint(f, 10, 64); err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid cpu max %q, expected \"$MAX [$PERIOD]\"", cpuMax)
+			}
+		}
+		l.CPUMax = strings.Join(fields, " ")
+	}
+	if pidsMax != "" {
+		if pidsMax != "max" {
+			if _, err := strconv.ParseUint(pidsMax, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid pids max %q", pidsMax)
+			}
+		}
+		l.PidsMax = pidsMax
+	}
+	if ioWeight != 0 {
+		if ioWeight > 10000 {
+			return nil, fmt.Errorf("io weight must be in the range of [1, 10000], got %d", ioWeight)
+		}
+		l.IOWeight = "default " + strconv.FormatUint(ioWeight, 10)
+	}
+	return &l, nil
+}
+
+func parseBytes(s string) (string, error) {
+	if s == "max" {
+		return s, nil
+	}
+	mul := uint64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		mul = 1 << 10
+	case "M":
+		mul = 1 << 20
+	case "G":
+		mul = 1 << 30
+	case "T":
+		mul = 1 << 40
+	}
+	if mul != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	if n > (1<<64-1)/mul {
+		return "", errors.New("too large")
+	}
+	return strconv.FormatUint(n*mul, 10), nil
+}
+
+// SetLimits writes the limits to the cgroup directory, e.g. "/sys/fs/cgroup/foo/bar".
+// The controllers have to be enabled in "cgroup.subtree_control" of the parent cgroup.
+func SetLimits(dir string, l *Limits) error {
+	for _, f := range []struct {
+		file  string
+		value string
+	}{
+		{"memory.max", l.MemoryMax},
+		{"cpu.max", l.CPUMax},
+		{"pids.max", l.PidsMax},
+		{"io.weight", l.IOWeight},
+	} {
+		if f.value == "" {
+			continue
+		}
+		p := filepath.Join(dir, f.file)
+		if err := writeFile(p, f.value); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				controller, _, _ := strings.Cut(f.file, ".")
+				return fmt.Errorf("failed to write %q: the %q controller is not enabled or not delegated: %w", p, controller, err)
+			}
+			return fmt.Errorf("failed to write %q to %q: %w", f.value, p, err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the file without creating it, as the interface files of cgroup2 cannot be created.
+func writeFile(p, value string) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Path returns the cgroup2 directory of the process, e.g. "/sys/fs/cgroup/foo/bar".
+// Path returns an empty string when cgroup2 is not enabled.
+func Path(pid int) string {
+	mountpoint := findCgroup2Mountpoint()
+	if mountpoint == "" {
+		return ""
+	}
+	group := getCgroup2(pid)
+	if group == "" {
+		return ""
+	}
+	return filepath.Join(mountpoint, group)
+}
+
+// Stat returns the current limits and usage of the cgroup directory.
+// The values of the disabled controllers are left empty.
+func Stat(dir string) (*api.Cgroup, error) {
+	readString := func(file string) string {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	readUint := func(s string) uint64 {
+		n, _ := strconv.ParseUint(s, 10, 64)
+		return n
+	}
+	controllers, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return nil, err
+	}
+	res := &api.Cgroup{
+		Path:          dir,
+		Controllers:   strings.Fields(string(controllers)),
+		MemoryMax:     readString("memory.max"),
+		MemoryCurrent: readUint(readString("memory.current")),
+		CPUMax:        readString("cpu.max"),
+		PidsMax:       readString("pids.max"),
+		PidsCurrent:   readUint(readString("pids.current")),
+	}
+	for _, l := range strings.Split(readString("cpu.stat"), "\n") {
+		if k, v, ok := strings.Cut(l, " "); ok && k == "usage_usec" {
+			res.CPUUsageUsec = readUint(v)
+		}
+	}
+	for _, l := range strings.Split(readString("io.weight"), "\n") {
+		if k, v, ok := strings.Cut(l, " "); ok && k == "default" {
+			res.IOWeight = readUint(v)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,72 @@
+package cgrouputil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewLimits(t *testing.T) {
+	l, err := NewLimits("", "", "", 0)
+	assert.NilError(t, err)
+	assert.Check(t, l == nil)
+
+	l, err = NewLimits("4G", "200000  100000", "max", 200)
+	assert.NilError(t, err)
+	assert.Equal(t, Limits{
+		MemoryMax: "4294967296",
+		CPUMax:    "200000 100000",
+		PidsMax:   "max",
+		IOWeight:  "default 200",
+	}, *l)
+
+	l, err = NewLimits("max", "max", "1000", 0)
+	assert.NilError(t, err)
+	assert.Equal(t, Limits{MemoryMax: "max", CPUMax: "max", PidsMax: "1000"}, *l)
+
+	_, err = NewLimits("4X", "", "", 0)
+	assert.ErrorContains(t, err, "invalid memory max")
+	_, err = NewLimits("", "0", "", 0)
+	assert.ErrorContains(t, err, "invalid cpu max")
+	_, err = NewLimits("", "100000 max", "", 0)
+	assert.ErrorContains(t, err, "invalid cpu max")
+	_, err = NewLimits("", "", "-1", 0)
+	assert.ErrorContains(t, err, "invalid pids max")
+	_, err = NewLimits("", "", "", 10001)
+	assert.ErrorContains(t, err, "io weight")
+}
+
+func TestSetLimitsAndStat(t *testing.T) {
+	dir := t.TempDir()
+	for f, s := range map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"memory.max":         "max\n",
+		"memory.current":     "4096\n",
+		"cpu.max":            "max 100000\n",
+		"cpu.stat":           "usage_usec 42\nuser_usec 40\nsystem_usec 2\n",
+		"pids.max":           "max\n",
+		"pids.current":       "3\n",
+	} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, f), []byte(s), 0644))
+	}
+	l, err := NewLimits("1M", "50000", "10", 0)
+	assert.NilError(t, err)
+	assert.NilError(t, SetLimits(dir, l))
+
+	cg, err := Stat(dir)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"cpu", "memory", "pids"}, cg.Controllers)
+	assert.Equal(t, "1048576", cg.MemoryMax)
+	assert.Equal(t, uint64(4096), cg.MemoryCurrent)
+	assert.Equal(t, "50000", cg.CPUMax)
+	assert.Equal(t, uint64(42), cg.CPUUsageUsec)
+	assert.Equal(t, "10", cg.PidsMax)
+	assert.Equal(t, uint64(3), cg.PidsCurrent)
+	assert.Equal(t, uint64(0), cg.IOWeight)
+
+	l, err = NewLimits("", "", "", 100)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, SetLimits(dir, l), "\"io\" controller is not enabled")
+}
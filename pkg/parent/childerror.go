@@ -0,0 +1,65 @@
+package parent
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
+)
+
+// ChildError is the error reported by the child.
+// Parent returns an error that wraps *ChildError when the child failed.
+//
+// e.g.,
+//
+//	var childErr *parent.ChildError
+//	if errors.As(err, &childErr) && childErr.Phase == parent.PhaseCopyUp { ... }
+type ChildError struct {
+	Phase   Phase
+	Message string
+	Errno   syscall.Errno // 0 if unknown
+}
+
+func (e *ChildError) Error() string {
+	return fmt.Sprintf("child failed in phase %q: %s", e.Phase, e.Message)
+}
+
+// Unwrap returns Errno, so that errors.Is(err, syscall.EPERM) can be used.
+func (e *ChildError) Unwrap() error {
+	if e.Errno == 0 {
+		return nil
+	}
+	return e.Errno
+}
+
+func newChildError(m *messages.ChildError) *ChildError {
+	return &ChildError{
+		Phase:   Phase(m.Phase),
+		Message: m.ErrorMessage,
+		Errno:   syscall.Errno(m.Errno),
+	}
+}
+
+// waitForChild is similar to messages.WaitFor, but returns *ChildError when the child sent messages.ChildError.
+func waitForChild(r io.Reader, name string) (*messages.Message, error) {
+	for {
+		msg, err := messages.Recv(r)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Name == messages.Name(messages.ChildError{}) && msg.U.ChildError != nil {
+			return nil, newChildError(msg.U.ChildError)
+		}
+		if msg.Name != name {
+			if !messages.Known(msg.Name) {
+				logrus.Debugf("Skipping unknown message %q", msg.Name)
+				continue
+			}
+			return nil, fmt.Errorf("expected %q, got %+v", name, msg)
+		}
+		return msg, nil
+	}
+}
@@ -0,0 +1,83 @@
+package parent
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
+)
+
+// childMessages receives the messages sent from the child after the initialization.
+type childMessages struct {
+	targetRestarts atomic.Int64
+	landlockABI    atomic.Pointer[int] // nil until the child reports the enforced Landlock ABI version
+	err            atomic.Pointer[ChildError]
+	control        *controlClient // can be nil
+	done           chan struct{}
+}
+
+func newChildMessages(control *controlClient) *childMessages {
+	return &childMessages{
+		control: control,
+		done:    make(chan struct{}),
+	}
+}
+
+// recv receives the messages until r is closed.
+// The control channel is closed when recv returns.
+func (cm *childMessages) recv(r io.Reader, bus *events.Bus) {
+	defer close(cm.done)
+	defer cm.control.close()
+	for {
+		msg, err := messages.Recv(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+				logrus.WithError(err).Debug("stopped receiving messages from the child")
+			}
+			return
+		}
+		switch msg.Name {
+		case messages.Name(messages.ChildTargetRestarted{}):
+			m := msg.U.ChildTargetRestarted
+			if m == nil {
+				m = &messages.ChildTargetRestarted{}
+			}
+			cm.targetRestarts.Store(int64(m.Restarts))
+			code := m.ExitCode
+			bus.Publish(events.Event{Type: events.TypeTargetRestarted, ExitCode: &code, Restarts: m.Restarts})
+		case messages.Name(messages.ChildLandlockApplied{}):
+			if m := msg.U.ChildLandlockApplied; m != nil {
+				abi := m.LandlockABI
+				cm.landlockABI.Store(&abi)
+			}
+		case messages.Name(messages.ChildResponse{}):
+			if m := msg.U.ChildResponse; m != nil && cm.control != nil {
+				cm.control.handleResponse(m)
+			}
+		case messages.Name(messages.ChildError{}):
+			if m := msg.U.ChildError; m != nil {
+				cm.err.Store(newChildError(m))
+			}
+		default:
+			logrus.Debugf("Skipping unexpected message %q", msg.Name)
+		}
+	}
+}
+
+// childError returns the error reported by the child, after waiting for the child to close the pipe.
+// childError returns nil if the child did not report an error.
+func (cm *childMessages) childError() *ChildError {
+	select {
+	case <-cm.done:
+	case <-time.After(3 * time.Second):
+		// Should not happen, as the pipe is not inherited to the target command
+		logrus.Warn("timed out waiting for the child to close the pipe")
+	}
+	return cm.err.Load()
+}
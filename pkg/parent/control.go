@@ -0,0 +1,144 @@
+package parent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
+)
+
+var errControlClosed = errors.New("the control channel to the child is closed")
+
+// controlClient sends ParentRequest to the child after the initialization.
+// controlClient implements router.ChildController.
+type controlClient struct {
+	w       io.WriteCloser
+	writeMu sync.Mutex
+	mu      sync.Mutex // protects the fields below
+	nextID  uint64
+	pending map[uint64]chan *messages.ChildResponse
+	closed  bool
+}
+
+func newControlClient(w io.WriteCloser) *controlClient {
+	return &controlClient{
+		w:       w,
+		pending: make(map[uint64]chan *messages.ChildResponse),
+	}
+}
+
+// call sends the request, and waits for the response.
+// result can be nil.
+func (c *controlClient) call(ctx context.Context, op string, args, result interface{}) error {
+	rawArgs, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	ch := make(chan *messages.ChildResponse, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errControlClosed
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	msg := &messages.Message{
+		U: messages.U{
+			ParentRequest: &messages.ParentRequest{
+				RequestID:   id,
+				RequestOp:   op,
+				RequestArgs: rawArgs,
+			},
+		},
+	}
+	c.writeMu.Lock()
+	err = messages.Send(c.w, msg)
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case resp, ok := <-ch:
+		if !ok {
+			return errControlClosed
+		}
+		if resp.ResponseError != "" {
+			return &controlError{message: resp.ResponseError, errno: syscall.Errno(resp.ResponseErrno)}
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.ResponseResult, result)
+	}
+}
+
+// handleResponse passes the response to the caller.
+func (c *controlClient) handleResponse(resp *messages.ChildResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.pending[resp.ResponseID]; ok {
+		ch <- resp
+		delete(c.pending, resp.ResponseID)
+	}
+}
+
+// close closes the channel, and fails the pending calls.
+// close is no-op for a nil controlClient.
+func (c *controlClient) close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	return c.w.Close()
+}
+
+func (c *controlClient) Sysctl(ctx context.Context, key string, value *string) (string, error) {
+	args := &messages.SysctlArgs{
+		Key:   key,
+		Value: value,
+	}
+	var res messages.SysctlResult
+	if err := c.call(ctx, messages.OpSysctl, args, &res); err != nil {
+		return "", err
+	}
+	return res.Value, nil
+}
+
+// controlError is the error returned by the child for ParentRequest.
+type controlError struct {
+	message string
+	errno   syscall.Errno // 0 if unknown
+}
+
+func (e *controlError) Error() string {
+	return e.message
+}
+
+func (e *controlError) Unwrap() error {
+	if e.errno == 0 {
+		return nil
+	}
+	return e.errno
+}
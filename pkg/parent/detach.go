@@ -0,0 +1,146 @@
+package parent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/logfile"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/lowlevelmsgutil"
+)
+
+// detachResult is sent from the detached daemon to the foreground process.
+type detachResult struct {
+	Error string `json:",omitempty"`
+}
+
+// spawnDetached re-executes RootlessKit as a daemon in a new session, and waits until the daemon becomes ready.
+func spawnDetached(opt Opt) error {
+	if os.Getenv("LISTEN_FDS") != "" {
+		return errors.New("detach mode cannot be used with systemd socket activation")
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	// The state dir is passed explicitly, as it may have been created as a temporary directory.
+	// The daemon inherits the current directory, so the relative paths in os.Args remain valid.
+	args := append([]string{"--state-dir=" + opt.StateDir}, os.Args[1:]...)
+	cmd := exec.Command("/proc/self/exe", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	// fd 3
+	cmd.ExtraFiles = []*os.File{readyW}
+	cmd.Env = append(os.Environ(), opt.DetachEnvKey+"=3")
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start the daemon: %w", err)
+	}
+	var res detachResult
+	if _, err := lowlevelmsgutil.UnmarshalFromReader(readyR, &res); err != nil {
+		if waitErr := cmd.Wait(); waitErr != nil {
+			return fmt.Errorf("daemon exited before becoming ready: %w", waitErr)
+		}
+		return fmt.Errorf("failed to read the readiness of the daemon: %w", err)
+	}
+	if res.Error != "" {
+		_ = cmd.Wait()
+		return fmt.Errorf("daemon failed: %s", res.Error)
+	}
+	logrus.Infof("Running in detached mode (PID=%d, state dir=%s)", cmd.Process.Pid, opt.StateDir)
+	return cmd.Process.Release()
+}
+
+// daemon is the detached RootlessKit process.
+type daemon struct {
+	ready    *os.File
+	notified bool
+	log      *logfile.Writer
+}
+
+func newDaemon(opt Opt, fdStr string) (*daemon, error) {
+	if err := os.Unsetenv(opt.DetachEnvKey); err != nil {
+		return nil, err
+	}
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected fd value: %s: %w", fdStr, err)
+	}
+	d := &daemon{
+		ready: os.NewFile(uintptr(fd), "ready"),
+	}
+	logPath := opt.LogFile
+	if logPath == "" {
+		logPath = filepath.Join(opt.StateDir, StateFileLog)
+	}
+	d.log, err = logfile.New(logPath, logfile.DefaultMaxSize, logfile.DefaultMaxBackups)
+	if err != nil {
+		d.notify(err)
+		return nil, err
+	}
+	logrus.SetOutput(d.log.Stderr())
+	return d, nil
+}
+
+// notify notifies the foreground process of the result.
+// Only the first call is effective.
+func (d *daemon) notify(err error) {
+	if d.notified {
+		return
+	}
+	d.notified = true
+	var res detachResult
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if _, err := lowlevelmsgutil.MarshalToWriter(d.ready, &res); err != nil {
+		logrus.WithError(err).Warn("failed to notify the readiness")
+	}
+	d.ready.Close()
+}
+
+func (d *daemon) close() error {
+	logrus.SetOutput(os.Stderr)
+	return d.log.Close()
+}
+
+// runDaemon runs the parent as the detached daemon.
+// The stdio of the child is connected to the log file.
+func runDaemon(opt Opt, fdStr string) error {
+	d, err := newDaemon(opt, fdStr)
+	if err != nil {
+		return err
+	}
+	defer d.close()
+	err = parent(opt, d)
+	if err != nil {
+		if d.notified {
+			logrus.WithError(err).Error("exiting")
+		} else {
+			d.notify(err)
+		}
+	}
+	return err
+}
+
+// setupStdio connects the stdio of cmd to the daemon.
+// The returned writer is connected to the stdin of cmd.
+func (d *daemon) setupStdio(cmd *exec.Cmd) (io.Writer, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = d.log.Stdout()
+	cmd.Stderr = d.log.Stderr()
+	return stdin, nil
+}
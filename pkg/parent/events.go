@@ -0,0 +1,100 @@
+package parent
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/network"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
+)
+
+// eventPortDriver publishes the port events.
+type eventPortDriver struct {
+	port.ParentDriver
+	bus *events.Bus
+}
+
+// pausableEventPortDriver is an eventPortDriver that implements port.Pauser of the wrapped driver.
+type pausableEventPortDriver struct {
+	*eventPortDriver
+	port.Pauser
+}
+
+func newEventPortDriver(d port.ParentDriver, bus *events.Bus) port.ParentDriver {
+	ed := &eventPortDriver{ParentDriver: d, bus: bus}
+	if pauser, ok := d.(port.Pauser); ok {
+		return &pausableEventPortDriver{eventPortDriver: ed, Pauser: pauser}
+	}
+	return ed
+}
+
+func (d *eventPortDriver) AddPort(ctx context.Context, spec port.Spec) (*port.Status, error) {
+	st, err := d.ParentDriver.AddPort(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	d.bus.Publish(events.Event{Type: events.TypePortAdded, Port: st})
+	return st, nil
+}
+
+func (d *eventPortDriver) RemovePort(ctx context.Context, id int) error {
+	var removed *port.Status
+	if ports, err := d.ParentDriver.ListPorts(ctx); err == nil {
+		for _, p := range ports {
+			if p.ID == id {
+				removed = &p
+				break
+			}
+		}
+	}
+	if err := d.ParentDriver.RemovePort(ctx, id); err != nil {
+		return err
+	}
+	if removed == nil {
+		removed = &port.Status{ID: id}
+	}
+	d.bus.Publish(events.Event{Type: events.TypePortRemoved, Port: removed})
+	return nil
+}
+
+// watchHelper publishes events.TypeHelperExited when the helper process of the network driver exits before stop is closed.
+func watchHelper(driver network.ParentDriver, bus *events.Bus, stop <-chan struct{}) {
+	hw, ok := driver.(network.HelperWatcher)
+	if !ok {
+		return
+	}
+	exited := hw.HelperExited()
+	if exited == nil {
+		return
+	}
+	var helper string
+	if info, err := driver.Info(context.TODO()); err == nil {
+		helper = info.Driver
+	}
+	go func() {
+		select {
+		case <-stop:
+		case <-exited:
+			logrus.Warnf("The helper process of the network driver %q exited", helper)
+			bus.Publish(events.Event{Type: events.TypeHelperExited, Helper: helper})
+		}
+	}()
+}
+
+// childExitedEvent returns events.TypeChildExited event for the process state.
+func childExitedEvent(st *os.ProcessState) events.Event {
+	ev := events.Event{Type: events.TypeChildExited}
+	if st == nil {
+		return ev
+	}
+	code := st.ExitCode()
+	ev.ExitCode = &code
+	if ws, ok := st.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		ev.Signal = ws.Signal().String()
+	}
+	return ev
+}
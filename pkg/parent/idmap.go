@@ -0,0 +1,447 @@
+package parent
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/dynidtools"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/idtools"
+	"github.com/sirupsen/logrus"
+)
+
+// IDMap is an entry of uid_map or gid_map.
+type IDMap struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+func (m IDMap) String() string {
+	return fmt.Sprintf("%d:%d:%d", m.ContainerID, m.HostID, m.Size)
+}
+
+// ParseIDMap parses "CONTAINER:HOST:SIZE", e.g., "0:1001:1".
+func ParseIDMap(s string) (IDMap, error) {
+	fields := strings.Split(s, ":")
+	if len(fields) != 3 {
+		return IDMap{}, fmt.Errorf("invalid id map %q, expected CONTAINER:HOST:SIZE", s)
+	}
+	var v [3]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return IDMap{}, fmt.Errorf("invalid id map %q, expected CONTAINER:HOST:SIZE", s)
+		}
+		v[i] = n
+	}
+	if v[2] == 0 {
+		return IDMap{}, fmt.Errorf("invalid id map %q: size must not be zero", s)
+	}
+	return IDMap{ContainerID: v[0], HostID: v[1], Size: v[2]}, nil
+}
+
+// getSubIDRanges returns the subid ranges of the user.
+// The static source reads subuidFile and subgidFile instead of /etc/subuid and /etc/subgid, when specified.
+func getSubIDRanges(u *user.User, subidSource SubidSource, subuidFile, subgidFile string) ([]idtools.SubIDRange, []idtools.SubIDRange, error) {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch subidSource {
+	case SubidSourceStatic:
+		logrus.Debug("subid-source: using the static source")
+		return idtools.GetSubIDRangesFromFiles(uid, u.Username, subuidFile, subgidFile)
+	case SubidSourceDynamic:
+		logrus.Debug("subid-source: using the dynamic source")
+		return dynidtools.GetSubIDRanges(uid, u.Username)
+	case SubidSourceNone:
+		return nil, nil, nil
+	case "", SubidSourceAuto:
+		if subuidFile != "" || subgidFile != "" {
+			return getSubIDRanges(u, SubidSourceStatic, subuidFile, subgidFile)
+		}
+		subuidRanges, subgidRanges, err := getSubIDRanges(u, SubidSourceDynamic, "", "")
+		if err == nil && len(subuidRanges) > 0 && len(subgidRanges) > 0 {
+			return subuidRanges, subgidRanges, nil
+		}
+		logrus.WithError(err).Debugf("failed to use subid source %q, falling back to %q", SubidSourceDynamic, SubidSourceStatic)
+		return getSubIDRanges(u, SubidSourceStatic, "", "")
+	default:
+		return nil, nil, fmt.Errorf("unknown subid source %q", subidSource)
+	}
+}
+
+// newugidmapArgsFromSubIDRanges returns the arguments of newuidmap and newgidmap.
+func newugidmapArgsFromSubIDRanges(u *user.User, subuidRanges, subgidRanges []idtools.SubIDRange,
+	uidMap, gidMap []IDMap, maxSubIDs int) ([]string, []string, error) {
+	uidMap, gidMap, err := idMapsFromSubIDRanges(u, subuidRanges, subgidRanges, uidMap, gidMap, maxSubIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return idMapArgs(uidMap), idMapArgs(gidMap), nil
+}
+
+// idMapsFromSubIDRanges returns the entries of uid_map and gid_map.
+// The explicit mappings (uidMap, gidMap) are used when specified, otherwise the caller is mapped to 0,
+// and the subids are mapped from 1.
+// maxSubIDs limits the number of the mapped subids, when positive.
+func idMapsFromSubIDRanges(u *user.User, subuidRanges, subgidRanges []idtools.SubIDRange,
+	uidMap, gidMap []IDMap, maxSubIDs int) ([]IDMap, []IDMap, error) {
+	uid, gid, err := userIDs(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uidMap == nil {
+		uidMap = defaultIDMaps(uid, subuidRanges, maxSubIDs)
+	} else if err := validateIDMaps(uidMap, uid, subuidRanges, maxSubIDs); err != nil {
+		return nil, nil, fmt.Errorf("invalid uid map: %w", err)
+	}
+	if gidMap == nil {
+		gidMap = defaultIDMaps(gid, subgidRanges, maxSubIDs)
+	} else if err := validateIDMaps(gidMap, gid, subgidRanges, maxSubIDs); err != nil {
+		return nil, nil, fmt.Errorf("invalid gid map: %w", err)
+	}
+	return uidMap, gidMap, nil
+}
+
+func userIDs(u *user.User) (int, int, error) {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uid, gid, nil
+}
+
+// defaultIDMaps maps id to 0, and the subids from 1.
+func defaultIDMaps(id int, ranges []idtools.SubIDRange, maxSubIDs int) []IDMap {
+	maps := []IDMap{{ContainerID: 0, HostID: id, Size: 1}}
+	last := 1
+	for _, r := range ranges {
+		size := r.Length
+		if maxSubIDs > 0 {
+			size = min(size, maxSubIDs-(last-1))
+		}
+		if size <= 0 {
+			break
+		}
+		maps = append(maps, IDMap{ContainerID: last, HostID: r.Start, Size: size})
+		last += size
+	}
+	return maps
+}
+
+// validateIDMaps validates the explicit mappings.
+// id has to be mapped to 0, as the child needs to be root in the user namespace.
+// The other host IDs have to be in the subid ranges.
+func validateIDMaps(maps []IDMap, id int, ranges []idtools.SubIDRange, maxSubIDs int) error {
+	if len(maps) > maxIDMapExtents {
+		return fmt.Errorf("too many entries (%d), up to %d entries are supported", len(maps), maxIDMapExtents)
+	}
+	var rootMapped bool
+	var subIDs int
+	for i, m := range maps {
+		for _, m2 := range maps[:i] {
+			if m.ContainerID < m2.ContainerID+m2.Size && m2.ContainerID < m.ContainerID+m.Size {
+				return fmt.Errorf("the container ids of %s overlap with %s", m, m2)
+			}
+			if m.HostID < m2.HostID+m2.Size && m2.HostID < m.HostID+m.Size {
+				return fmt.Errorf("the host ids of %s overlap with %s", m, m2)
+			}
+		}
+		if m.HostID <= id && id < m.HostID+m.Size {
+			if m.ContainerID+(id-m.HostID) != 0 {
+				return fmt.Errorf("%s maps the host id %d to %d, but it has to be mapped to 0 (hint: use --userns=keep-id for mapping it to %d)",
+					m, id, m.ContainerID+(id-m.HostID), id)
+			}
+			rootMapped = true
+			if m.Size == 1 {
+				continue
+			}
+			return fmt.Errorf("%s contains the host id %d and other ids; specify the host id %d as \"0:%d:1\"", m, id, id, id)
+		}
+		var inRange bool
+		for _, r := range ranges {
+			if r.Start <= m.HostID && m.HostID+m.Size <= r.Start+r.Length {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return fmt.Errorf("the host ids of %s are not in the subid ranges %v", m, ranges)
+		}
+		subIDs += m.Size
+	}
+	if !rootMapped {
+		return fmt.Errorf("the host id %d has to be mapped to 0, e.g., \"0:%d:1\"", id, id)
+	}
+	if maxSubIDs > 0 && subIDs > maxSubIDs {
+		return fmt.Errorf("%d subids are mapped, exceeding the limit %d", subIDs, maxSubIDs)
+	}
+	return nil
+}
+
+func idMapArgs(maps []IDMap) []string {
+	var args []string
+	for _, m := range maps {
+		args = append(args, strconv.Itoa(m.ContainerID), strconv.Itoa(m.HostID), strconv.Itoa(m.Size))
+	}
+	return args
+}
+
+// setupUIDGIDMap writes the uid_map and the gid_map of the child, and returns the effective mappings.
+//
+// The mappings are written with newuidmap and newgidmap, unless the subid source is "none".
+// The "auto" source falls back to "none" when the subids or newuidmap and newgidmap are unavailable.
+func setupUIDGIDMap(pid int, opt Opt) (*api.IDMapInfo, error) {
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	subidSource := opt.SubidSource
+	if subidSource != SubidSourceNone {
+		subuidRanges, subgidRanges, err := getSubIDRanges(u, subidSource, opt.SubuidFile, opt.SubgidFile)
+		if err == nil {
+			err = lookPathNewugidmap()
+		}
+		if err == nil {
+			logrus.Debugf("subuid ranges=%v", subuidRanges)
+			logrus.Debugf("subgid ranges=%v", subgidRanges)
+			uid, gid, err := userIDs(u)
+			if err != nil {
+				return nil, err
+			}
+			subuidRanges, err = validateSubIDRanges(subuidRanges, uid, "subuid", subidConfig(opt, "subuid"))
+			if err != nil {
+				return nil, err
+			}
+			subgidRanges, err = validateSubIDRanges(subgidRanges, gid, "subgid", subidConfig(opt, "subgid"))
+			if err != nil {
+				return nil, err
+			}
+			return setupUIDGIDMapWithNewugidmap(pid, u, subuidRanges, subgidRanges, opt)
+		}
+		if (subidSource != "" && subidSource != SubidSourceAuto) || opt.SubuidFile != "" || opt.SubgidFile != "" ||
+			opt.UIDMap != nil || opt.GIDMap != nil {
+			return nil, err
+		}
+		logrus.WithError(err).Warnf("The subids are not available, falling back to subid source %q", SubidSourceNone)
+		subidSource = SubidSourceNone
+	}
+	if opt.UIDMap != nil || opt.GIDMap != nil {
+		return nil, fmt.Errorf("subid source %q cannot be used with the explicit uid map and gid map", SubidSourceNone)
+	}
+	return setupSingleIDMap(pid, u)
+}
+
+// maxIDMapExtents is the maximum number of the entries of uid_map and gid_map (Linux 4.15 and later).
+const maxIDMapExtents = 340
+
+// maxID is the maximum valid uid and gid. (uid_t)-1 is invalid.
+const maxID = 1<<32 - 2
+
+// validateSubIDRanges validates the subid ranges before calling newuidmap and newgidmap.
+// Overlapping and duplicate ranges are merged.
+// kind is "subuid" or "subgid", and config describes where the ranges are configured, for the error messages.
+func validateSubIDRanges(ranges []idtools.SubIDRange, id int, kind, config string) ([]idtools.SubIDRange, error) {
+	idKind := strings.TrimPrefix(kind, "sub")
+	for _, r := range ranges {
+		if r.Start < 0 || r.Length <= 0 {
+			return nil, fmt.Errorf("invalid %s range %d:%d; the start must not be negative and the length must be positive (hint: fix %s)",
+				kind, r.Start, r.Length, config)
+		}
+		if r.Start+r.Length-1 > maxID {
+			return nil, fmt.Errorf("the %s range %d:%d exceeds the maximum %s %d (hint: fix %s)",
+				kind, r.Start, r.Length, idKind, maxID, config)
+		}
+		if r.Start <= id && id < r.Start+r.Length {
+			return nil, fmt.Errorf("the %s range %d:%d contains the %s %d of the current user, which is already mapped to 0 (hint: fix %s)",
+				kind, r.Start, r.Length, idKind, id, config)
+		}
+	}
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b idtools.SubIDRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	var merged []idtools.SubIDRange
+	for _, r := range sorted {
+		if l := len(merged); l > 0 && r.Start < merged[l-1].Start+merged[l-1].Length {
+			last := &merged[l-1]
+			last.Length = max(last.Length, r.Start+r.Length-last.Start)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	if len(merged) != len(ranges) {
+		logrus.Warnf("The %s ranges %v overlap, merged into %v (hint: fix %s)", kind, ranges, merged, config)
+		ranges = merged
+	}
+	if len(ranges)+1 > maxIDMapExtents {
+		return nil, fmt.Errorf("too many %s ranges (%d); %s_map can have up to %d entries, including the current user (hint: fix %s)",
+			kind, len(ranges), idKind, maxIDMapExtents, config)
+	}
+	return ranges, nil
+}
+
+// subidConfig describes where the subid ranges are configured, for the error messages.
+// kind is "subuid" or "subgid".
+func subidConfig(opt Opt, kind string) string {
+	f := opt.SubuidFile
+	if kind == "subgid" {
+		f = opt.SubgidFile
+	}
+	switch {
+	case f != "":
+		return f
+	case opt.SubidSource == SubidSourceDynamic:
+		return "the subid database of getsubids"
+	case opt.SubidSource == SubidSourceStatic:
+		return "/etc/" + kind
+	default:
+		return "/etc/" + kind + " or the subid database of getsubids"
+	}
+}
+
+func lookPathNewugidmap() error {
+	for _, f := range []string{"newuidmap", "newgidmap"} {
+		if _, err := exec.LookPath(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setupUIDGIDMapWithNewugidmap(pid int, u *user.User, subuidRanges, subgidRanges []idtools.SubIDRange, opt Opt) (*api.IDMapInfo, error) {
+	uidMap, gidMap, err := idMapsFromSubIDRanges(u, subuidRanges, subgidRanges, opt.UIDMap, opt.GIDMap, opt.MaxSubIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute uid/gid map: %w", err)
+	}
+	if opt.SubuidFile != "" || opt.SubgidFile != "" {
+		if err := checkSubIDFilesAllocated(u, uidMap, gidMap, opt); err != nil {
+			return nil, err
+		}
+	}
+	uArgs, gArgs := idMapArgs(uidMap), idMapArgs(gidMap)
+	pidS := strconv.Itoa(pid)
+	cmd := exec.Command("newuidmap", append([]string{pidS}, uArgs...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("newuidmap %s %v failed: %s: %w", pidS, uArgs, string(out), err)
+	}
+	cmd = exec.Command("newgidmap", append([]string{pidS}, gArgs...)...)
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("newgidmap %s %v failed: %s: %w", pidS, gArgs, string(out), err)
+	}
+	return &api.IDMapInfo{
+		UIDMap: apiIDMaps(uidMap),
+		GIDMap: apiIDMaps(gidMap),
+	}, nil
+}
+
+// checkSubIDFilesAllocated checks that the host ids of the mappings are allocated to the user in the subid database of the system,
+// as newuidmap and newgidmap check them against the database, regardless of --subuid-file and --subgid-file.
+func checkSubIDFilesAllocated(u *user.User, uidMap, gidMap []IDMap, opt Opt) error {
+	uid, gid, err := userIDs(u)
+	if err != nil {
+		return err
+	}
+	allocatedSubuids, allocatedSubgids, err := getSubIDRanges(u, SubidSourceAuto, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to get the subids allocated to %s, which are checked by newuidmap and newgidmap regardless of --subuid-file and --subgid-file: %w",
+			u.Username, err)
+	}
+	for _, f := range []struct {
+		kind, file string
+		id         int
+		maps       []IDMap
+		allocated  []idtools.SubIDRange
+	}{
+		{"subuid", opt.SubuidFile, uid, uidMap, allocatedSubuids},
+		{"subgid", opt.SubgidFile, gid, gidMap, allocatedSubgids},
+	} {
+		if f.file == "" {
+			continue
+		}
+		for _, m := range f.maps {
+			if m.HostID == f.id && m.Size == 1 {
+				continue
+			}
+			if !subIDRangesContain(f.allocated, m.HostID, m.Size) {
+				return fmt.Errorf("the %s range %d:%d in %s is not allocated to %s in %s, which new%smap checks regardless of --%s-file (hint: allocate the range in %s)",
+					f.kind, m.HostID, m.Size, f.file, u.Username, subidConfig(Opt{}, f.kind), strings.TrimPrefix(f.kind, "sub"), f.kind, subidConfig(Opt{}, f.kind))
+			}
+		}
+	}
+	return nil
+}
+
+// subIDRangesContain returns true if the ids from start to start+length-1 are contained in the union of ranges.
+func subIDRangesContain(ranges []idtools.SubIDRange, start, length int) bool {
+	end := start + length
+	for start < end {
+		found := false
+		for _, r := range ranges {
+			if r.Start <= start && start < r.Start+r.Length {
+				start = r.Start + r.Length
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setupSingleIDMap maps the current user to 0 by writing uid_map and gid_map directly, without the subids.
+// setgroups(2) has to be denied for writing gid_map without CAP_SETGID.
+func setupSingleIDMap(pid int, u *user.User) (*api.IDMapInfo, error) {
+	uid, gid, err := userIDs(u)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Warnf("Mapping only the current user (uid=%d, gid=%d) to root, without the subids. "+
+		"The files owned by the other users (e.g., in container images) cannot be handled, and setgroups(2) is denied. "+
+		"See https://rootlesscontaine.rs/getting-started/common/subuid/ for configuring the subids.", uid, gid)
+	uidMap := []IDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+	gidMap := []IDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+	for _, f := range []struct {
+		name, content string
+	}{
+		{"setgroups", "deny"},
+		{"uid_map", strings.Join(idMapArgs(uidMap), " ")},
+		{"gid_map", strings.Join(idMapArgs(gidMap), " ")},
+	} {
+		p := filepath.Join(procDir, f.name)
+		if err := os.WriteFile(p, []byte(f.content), 0); err != nil {
+			return nil, fmt.Errorf("failed to write %q to %s: %w", f.content, p, err)
+		}
+	}
+	return &api.IDMapInfo{
+		UIDMap:          apiIDMaps(uidMap),
+		GIDMap:          apiIDMaps(gidMap),
+		SetgroupsDenied: true,
+	}, nil
+}
+
+func apiIDMaps(maps []IDMap) []api.IDMap {
+	res := make([]api.IDMap, len(maps))
+	for i, m := range maps {
+		res[i] = api.IDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size}
+	}
+	return res
+}
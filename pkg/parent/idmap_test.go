@@ -0,0 +1,142 @@
+package parent
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/idtools"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseIDMap(t *testing.T) {
+	m, err := ParseIDMap("1:100000:65536")
+	assert.NilError(t, err)
+	assert.Equal(t, IDMap{ContainerID: 1, HostID: 100000, Size: 65536}, m)
+	assert.Equal(t, "1:100000:65536", m.String())
+
+	_, err = ParseIDMap("1:100000")
+	assert.ErrorContains(t, err, "CONTAINER:HOST:SIZE")
+	_, err = ParseIDMap("1:-1:1")
+	assert.ErrorContains(t, err, "CONTAINER:HOST:SIZE")
+	_, err = ParseIDMap("1:100000:0")
+	assert.ErrorContains(t, err, "must not be zero")
+}
+
+func TestNewugidmapArgsWithMaps(t *testing.T) {
+	ranges := []idtools.SubIDRange{
+		{Start: 100000, Length: 65536},
+		{Start: 200000, Length: 65536},
+	}
+	u := &user.User{Uid: "1001", Gid: "1002"}
+	uidMap := []IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 1, HostID: 100000, Size: 1000}}
+	uArgs, gArgs, err := newugidmapArgsFromSubIDRanges(u, ranges, ranges, uidMap, nil, 70000)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"0", "1001", "1", "1", "100000", "1000"}, uArgs)
+	assert.DeepEqual(t, []string{"0", "1002", "1", "1", "100000", "65536", "65537", "200000", "4464"}, gArgs)
+
+	for _, tc := range []struct {
+		maps     []IDMap
+		expected string
+	}{
+		{[]IDMap{{ContainerID: 1, HostID: 100000, Size: 1}}, "has to be mapped to 0"},
+		{[]IDMap{{ContainerID: 1001, HostID: 1001, Size: 1}}, "keep-id"},
+		{[]IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 1, HostID: 90000, Size: 1}}, "not in the subid ranges"},
+		{[]IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 1, HostID: 165000, Size: 1000}}, "not in the subid ranges"},
+		{[]IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 0, HostID: 100000, Size: 1}}, "overlap"},
+		{[]IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 1, HostID: 100000, Size: 70001}}, "not in the subid ranges"},
+		{[]IDMap{{ContainerID: 0, HostID: 1001, Size: 1}, {ContainerID: 1, HostID: 100000, Size: 65536}, {ContainerID: 65537, HostID: 200000, Size: 10000}}, "exceeding the limit"},
+	} {
+		_, _, err := newugidmapArgsFromSubIDRanges(u, ranges, ranges, tc.maps, nil, 70000)
+		assert.ErrorContains(t, err, tc.expected, "%v", tc.maps)
+	}
+}
+
+func TestGetSubIDRangesNone(t *testing.T) {
+	u := &user.User{Uid: "1001", Gid: "1001", Username: "penguin"}
+	subuidRanges, subgidRanges, err := getSubIDRanges(u, SubidSourceNone, "", "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(subuidRanges))
+	assert.Equal(t, 0, len(subgidRanges))
+}
+
+func TestGetSubIDRangesFromFiles(t *testing.T) {
+	d := t.TempDir()
+	subuidFile := filepath.Join(d, "subuid")
+	subgidFile := filepath.Join(d, "subgid")
+	assert.NilError(t, os.WriteFile(subuidFile, []byte("penguin:100000:65536\n1002:300000:65536\n"), 0644))
+	assert.NilError(t, os.WriteFile(subgidFile, []byte("1001:200000:65536\n"), 0644))
+	u := &user.User{Uid: "1001", Gid: "1001", Username: "penguin"}
+	subuidRanges, subgidRanges, err := getSubIDRanges(u, SubidSourceAuto, subuidFile, subgidFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []idtools.SubIDRange{{Start: 100000, Length: 65536}}, subuidRanges)
+	assert.DeepEqual(t, []idtools.SubIDRange{{Start: 200000, Length: 65536}}, subgidRanges)
+
+	u = &user.User{Uid: "1003", Gid: "1003", Username: "gopher"}
+	_, _, err = getSubIDRanges(u, SubidSourceStatic, subuidFile, subgidFile)
+	assert.ErrorContains(t, err, subuidFile)
+}
+
+func TestValidateSubIDRanges(t *testing.T) {
+	ranges := []idtools.SubIDRange{
+		{Start: 200000, Length: 65536},
+		{Start: 100000, Length: 65536},
+	}
+	res, err := validateSubIDRanges(ranges, 1001, "subuid", "/etc/subuid")
+	assert.NilError(t, err)
+	// the order is kept
+	assert.DeepEqual(t, ranges, res)
+
+	ranges = []idtools.SubIDRange{
+		{Start: 100000, Length: 65536},
+		{Start: 200000, Length: 65536},
+		{Start: 100000, Length: 65536},
+		{Start: 150000, Length: 60000},
+	}
+	res, err = validateSubIDRanges(ranges, 1001, "subuid", "/etc/subuid")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []idtools.SubIDRange{{Start: 100000, Length: 165536}}, res)
+
+	tooMany := make([]idtools.SubIDRange, maxIDMapExtents)
+	for i := range tooMany {
+		tooMany[i] = idtools.SubIDRange{Start: 100000 + i*10, Length: 10}
+	}
+	for _, tc := range []struct {
+		ranges   []idtools.SubIDRange
+		expected string
+	}{
+		{[]idtools.SubIDRange{{Start: 100000, Length: 0}}, "invalid subuid range 100000:0"},
+		{[]idtools.SubIDRange{{Start: -1, Length: 10}}, "invalid subuid range -1:10"},
+		{[]idtools.SubIDRange{{Start: 0, Length: 65536}}, "contains the uid 1001 of the current user"},
+		{[]idtools.SubIDRange{{Start: 4294900000, Length: 100000}}, "exceeds the maximum uid"},
+		{tooMany, "too many subuid ranges"},
+	} {
+		_, err := validateSubIDRanges(tc.ranges, 1001, "subuid", "/etc/subuid")
+		assert.ErrorContains(t, err, tc.expected)
+		assert.ErrorContains(t, err, "hint: fix /etc/subuid")
+	}
+}
+
+func TestSubIDRangesContain(t *testing.T) {
+	ranges := []idtools.SubIDRange{
+		{Start: 100000, Length: 65536},
+		{Start: 165536, Length: 1000},
+		{Start: 300000, Length: 10},
+	}
+	for _, tc := range []struct {
+		start, length int
+		expected      bool
+	}{
+		{100000, 65536, true},
+		{100010, 10, true},
+		{100000, 66536, true},
+		{100000, 66537, false},
+		{99999, 2, false},
+		{300000, 10, true},
+		{300005, 10, false},
+		{200000, 1, false},
+	} {
+		assert.Equal(t, tc.expected, subIDRangesContain(ranges, tc.start, tc.length), "%d:%d", tc.start, tc.length)
+	}
+}
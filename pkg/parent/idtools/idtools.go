@@ -20,31 +20,35 @@ const (
 )
 
 func GetSubIDRanges(uid int, username string) ([]SubIDRange, []SubIDRange, error) {
-	subuidRanges, err := parseSubuid(uid, username)
+	return GetSubIDRangesFromFiles(uid, username, "", "")
+}
+
+// GetSubIDRangesFromFiles is similar to GetSubIDRanges but reads the specified files
+// instead of /etc/subuid and /etc/subgid. An empty string means the default file.
+func GetSubIDRangesFromFiles(uid int, username, subuidFile, subgidFile string) ([]SubIDRange, []SubIDRange, error) {
+	if subuidFile == "" {
+		subuidFile = subuidFileName
+	}
+	if subgidFile == "" {
+		subgidFile = subgidFileName
+	}
+	subuidRanges, err := parseSubidFile(subuidFile, uid, username)
 	if err != nil {
 		return nil, nil, err
 	}
-	subgidRanges, err := parseSubgid(uid, username)
+	subgidRanges, err := parseSubidFile(subgidFile, uid, username)
 	if err != nil {
 		return nil, nil, err
 	}
 	if len(subuidRanges) == 0 {
-		return nil, nil, fmt.Errorf("No subuid ranges found for user %d (%q)", uid, username)
+		return nil, nil, fmt.Errorf("No subuid ranges found for user %d (%q) in %s", uid, username, subuidFile)
 	}
 	if len(subgidRanges) == 0 {
-		return nil, nil, fmt.Errorf("No subgid ranges found for user %d (%q)", uid, username)
+		return nil, nil, fmt.Errorf("No subgid ranges found for user %d (%q) in %s", uid, username, subgidFile)
 	}
 	return subuidRanges, subgidRanges, nil
 }
 
-func parseSubuid(uid int, username string) ([]SubIDRange, error) {
-	return parseSubidFile(subuidFileName, uid, username)
-}
-
-func parseSubgid(uid int, username string) ([]SubIDRange, error) {
-	return parseSubidFile(subgidFileName, uid, username)
-}
-
 // parseSubidFile will read the appropriate file (/etc/subuid or /etc/subgid)
 // and return all found ranges for a specified user. username is optional.
 func parseSubidFile(path string, uid int, username string) ([]SubIDRange, error) {
@@ -4,26 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
-	"os/user"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gofrs/flock"
 	"github.com/gorilla/mux"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/api/client"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/api/router"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/copyup/overlayfs"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/hook"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/network"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/cgrouputil"
-	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/dynidtools"
-	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/idtools"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/sigproxy"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/sigproxy/signal"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/systemd/notify"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
@@ -46,14 +55,43 @@ type Opt struct {
 	Propagation              string
 	EvacuateCgroup2          string // e.g. "rootlesskit_evacuation"
 	SubidSource              SubidSource
+	SubuidFile               string  // optional, read by the static source instead of /etc/subuid
+	SubgidFile               string  // optional, read by the static source instead of /etc/subgid
+	UIDMap                   []IDMap // optional, explicit uid_map entries. The caller has to be mapped to 0.
+	GIDMap                   []IDMap // optional, explicit gid_map entries. The caller has to be mapped to 0.
+	MaxSubIDs                int     // maximum number of the mapped subids, 0 for unlimited
+	Detach                   bool
+	DetachEnvKey             string      // needs to be set if Detach is true
+	LogFile                  string      // optional path of the log file for the detach mode, defaults to <StateDir>/log
+	Hooks                    []hook.Hook // the hooks of the child phases are ignored
+	SdNotify                 SdNotify
+	StopSignal               syscall.Signal     // signal sent to the child on SIGTERM and POST /v1/shutdown, defaults to SIGTERM
+	StopTimeout              time.Duration      // SIGKILL is sent when the child does not exit within StopTimeout after StopSignal. 0 for no timeout.
+	ResultFile               string             // optional path of the JSON file written on exit, see Result
+	CreateTimeNS             bool               // the time namespace is created by the child, for the target command
+	MonotonicOffset          time.Duration      // CLOCK_MONOTONIC offset of the time namespace
+	BoottimeOffset           time.Duration      // CLOCK_BOOTTIME offset of the time namespace
+	Landlock                 bool               // Landlock is applied to the target command by the child, reported in the info
+	CgroupLimits             *cgrouputil.Limits // optional, needs EvacuateCgroup2
+	CgroupFreezer            bool               // the child can be frozen via the API, needs EvacuateCgroup2
+	CopyUpPersistDirs        map[string]string  // optional, the persistent directories of the overlayfs copy-up, keyed by the copy-up directories
 }
 
 type SubidSource string
 
 const (
-	SubidSourceAuto    = SubidSource("auto")    // Try dynamic then fallback to static
+	SubidSourceAuto    = SubidSource("auto")    // Try dynamic then fallback to static, and then to none
 	SubidSourceDynamic = SubidSource("dynamic") // /usr/bin/getsubids
 	SubidSourceStatic  = SubidSource("static")  // /etc/{subuid,subgid}
+	SubidSourceNone    = SubidSource("none")    // Map only the current user, without newuidmap and newgidmap
+)
+
+type SdNotify string
+
+const (
+	SdNotifyNone    = SdNotify("none")    // Do not send notifications. $NOTIFY_SOCKET is propagated to the child as-is.
+	SdNotifyParent  = SdNotify("parent")  // Send READY=1 when the API is ready. $NOTIFY_SOCKET is not propagated to the child.
+	SdNotifyForward = SdNotify("forward") // Send READY=1 when the API is ready and the child sent READY=1.
 )
 
 // Documented state files. Undocumented ones are subject to change.
@@ -62,8 +100,54 @@ const (
 	StateFileChildPID = "child_pid" // decimal pid number text
 	StateFileAPISock  = "api.sock"  // REST API Socket
 	StateFileNetNs    = "netns"     // rootlesskit network namespace
+	StateFileLog      = "log"       // stdout and stderr of the detached child (since v3.1.0)
 )
 
+// cgroupChildGroupSuffix is appended to the evacuation group name, for the name of the group of the child.
+// The group of the child is created next to the evacuation group, only when the child needs its own group.
+const cgroupChildGroupSuffix = "_child"
+
+// freezeTimeout is the timeout for waiting for the cgroup to be frozen or thawed.
+const freezeTimeout = 30 * time.Second
+
+// freeze freezes or thaws the processes in dir.
+// The port driver refuses new connections while frozen. The port driver has to implement port.Pauser.
+func freeze(ctx context.Context, dir string, frozen bool, portDriver port.ParentDriver, bus *events.Bus) error {
+	pauser, ok := portDriver.(port.Pauser)
+	if portDriver != nil && !ok {
+		return errors.New("the port driver cannot refuse new connections while frozen")
+	}
+	ctx, cancel := context.WithTimeout(ctx, freezeTimeout)
+	defer cancel()
+	if frozen && pauser != nil {
+		pauser.Pause()
+	}
+	if err := cgrouputil.Freeze(ctx, dir, frozen); err != nil {
+		if frozen {
+			// best effort
+			thawCtx, thawCancel := context.WithTimeout(context.Background(), freezeTimeout)
+			_ = cgrouputil.Freeze(thawCtx, dir, false)
+			thawCancel()
+			if pauser != nil {
+				pauser.Resume()
+			}
+		}
+		return err
+	}
+	if !frozen && pauser != nil {
+		pauser.Resume()
+	}
+	typ := events.TypeThawed
+	if frozen {
+		typ = events.TypeFrozen
+	}
+	bus.Publish(events.Event{Type: typ})
+	return nil
+}
+
+// stateFileNotifySock is the sd_notify(3) socket for the child, created with SdNotifyForward.
+const stateFileNotifySock = "notify.sock"
+
 func checkPreflight(opt Opt) error {
 	if opt.PipeFDEnvKey == "" {
 		return errors.New("pipe FD env key is not set")
@@ -77,6 +161,36 @@ func checkPreflight(opt Opt) error {
 	if stat, err := os.Stat(opt.StateDir); err != nil || !stat.IsDir() {
 		return fmt.Errorf("state dir is inaccessible: %w", err)
 	}
+	if opt.Detach && opt.DetachEnvKey == "" {
+		return errors.New("detach env key is not set")
+	}
+	switch opt.SdNotify {
+	case "", SdNotifyNone:
+	case SdNotifyParent, SdNotifyForward:
+		if opt.Detach {
+			return fmt.Errorf("sd-notify %q cannot be used with the detach mode", opt.SdNotify)
+		}
+	default:
+		return fmt.Errorf("unknown sd-notify mode %q", opt.SdNotify)
+	}
+	if opt.CreateTimeNS {
+		if _, err := os.Stat("/proc/self/ns/time"); err != nil {
+			return fmt.Errorf("time namespace is not supported by the kernel (needs Linux 5.6 or later): %w", err)
+		}
+	} else if opt.MonotonicOffset != 0 || opt.BoottimeOffset != 0 {
+		return errors.New("the clock offsets require the time namespace")
+	}
+	if opt.CgroupLimits != nil && opt.EvacuateCgroup2 == "" {
+		return errors.New("the cgroup limits require the cgroup2 evacuation")
+	}
+	if opt.CgroupFreezer {
+		if opt.EvacuateCgroup2 == "" {
+			return errors.New("the cgroup freezer requires the cgroup2 evacuation")
+		}
+		if _, ok := opt.PortDriver.(port.Pauser); opt.PortDriver != nil && !ok {
+			return errors.New("the cgroup freezer requires a port driver that implements port.Pauser")
+		}
+	}
 
 	if os.Geteuid() == 0 {
 		logrus.Warn("Running RootlessKit as the root user is unsupported.")
@@ -152,6 +266,45 @@ func Parent(opt Opt) error {
 	if err := checkPreflight(opt); err != nil {
 		return err
 	}
+	if opt.Detach {
+		if fdStr := os.Getenv(opt.DetachEnvKey); fdStr != "" {
+			return runDaemon(opt, fdStr)
+		}
+		return spawnDetached(opt)
+	}
+	return parent(opt, nil)
+}
+
+// parent runs the parent. d is nil unless running as the detached daemon.
+func parent(opt Opt, d *daemon) error {
+	result := &Result{
+		StartedAt: time.Now(),
+		phase:     PhaseStart,
+	}
+	err := runParent(opt, d, result)
+	result.FinishedAt = time.Now()
+	if err != nil {
+		result.FailedPhase = result.phase
+		var childErr *ChildError
+		if errors.As(err, &childErr) {
+			result.FailedPhase = childErr.Phase
+		}
+		result.Error = err.Error()
+	}
+	if opt.ResultFile != "" {
+		if err := writeResult(opt.ResultFile, result); err != nil {
+			logrus.WithError(err).Warnf("failed to write the result file %q", opt.ResultFile)
+		}
+	}
+	return err
+}
+
+func runParent(opt Opt, d *daemon, result *Result) error {
+	bus := events.NewBus()
+	defer bus.Close()
+	if opt.PortDriver != nil {
+		opt.PortDriver = newEventPortDriver(opt.PortDriver, bus)
+	}
 
 	err := createCleanupLock(opt.StateDir)
 	if err != nil {
@@ -198,39 +351,130 @@ func Parent(opt Opt) error {
 	if opt.CreateIPCNS {
 		cmd.SysProcAttr.Unshareflags |= unix.CLONE_NEWIPC
 	}
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// childCgroupDir is the group of the child, created when the child is limited or frozen separately from the parent.
+	// The child is created in the group (CLONE_INTO_CGROUP), and then unshares the cgroup namespace,
+	// so that the group is the root of the cgroup namespace, and encloses the groups created by the target command.
+	var childCgroupDir string
+	if opt.CgroupLimits != nil || opt.CgroupFreezer {
+		childCgroupDir, err = cgrouputil.CreateSubgroup(opt.EvacuateCgroup2 + cgroupChildGroupSuffix)
+		if err != nil {
+			return fmt.Errorf("failed to create the cgroup for the child: %w", err)
+		}
+		// the group is left frozen when the previous instance was killed while frozen
+		if frozen, _ := cgrouputil.Frozen(childCgroupDir); frozen {
+			thawCtx, thawCancel := context.WithTimeout(context.Background(), freezeTimeout)
+			err = cgrouputil.Freeze(thawCtx, childCgroupDir, false)
+			thawCancel()
+			if err != nil {
+				return err
+			}
+		}
+		childCgroup, err := os.Open(childCgroupDir)
+		if err != nil {
+			return err
+		}
+		defer childCgroup.Close()
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(childCgroup.Fd())
+	}
+	var stdin io.Writer
+	if d != nil {
+		if stdin, err = d.setupStdio(cmd); err != nil {
+			return err
+		}
+	} else {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
 	cmd.ExtraFiles, cmd.Env = setupFilesAndEnv(pipeR, pipe2W, opt)
+	// documentedEnv is also propagated to the processes executed via the API
+	var documentedEnv []string
 	if opt.StateDirEnvKey != "" {
-		cmd.Env = append(cmd.Env, opt.StateDirEnvKey+"="+opt.StateDir)
+		documentedEnv = append(documentedEnv, opt.StateDirEnvKey+"="+opt.StateDir)
 	}
 	if opt.ParentEUIDEnvKey != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", opt.ParentEUIDEnvKey, os.Geteuid()))
+		documentedEnv = append(documentedEnv, fmt.Sprintf("%s=%d", opt.ParentEUIDEnvKey, os.Geteuid()))
 	}
 	if opt.ParentEGIDEnvKey != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", opt.ParentEGIDEnvKey, os.Getegid()))
+		documentedEnv = append(documentedEnv, fmt.Sprintf("%s=%d", opt.ParentEGIDEnvKey, os.Getegid()))
+	}
+	cmd.Env = append(cmd.Env, documentedEnv...)
+	var (
+		notifier         *notify.Notifier
+		forwarder        *notify.Forwarder
+		watchdogInterval time.Duration
+	)
+	if opt.SdNotify == SdNotifyParent || opt.SdNotify == SdNotifyForward {
+		notifier = notify.FromEnv()
+		cmd.Env = slices.DeleteFunc(cmd.Env, func(s string) bool {
+			return strings.HasPrefix(s, notify.EnvSocket+"=")
+		})
+		if opt.SdNotify == SdNotifyForward {
+			notifySockPath := filepath.Join(opt.StateDir, stateFileNotifySock)
+			forwarder, err = notify.NewForwarder(notifySockPath, notifier)
+			if err != nil {
+				return fmt.Errorf("failed to create the notification socket: %w", err)
+			}
+			defer forwarder.Close()
+			cmd.Env = append(cmd.Env, notify.EnvSocket+"="+notifySockPath)
+		}
+		watchdogInterval, err = notify.WatchdogInterval()
+		if err != nil {
+			return err
+		}
 	}
 	if err := cmd.Start(); err != nil {
 		warnOnChildStartFailure(err)
 		return fmt.Errorf("failed to start the child: %w", err)
 	}
+	// Close the ends of the pipes used by the child, so that the parent can receive EOF when the child exits
+	if err := pipe2W.Close(); err != nil {
+		return err
+	}
+	if err := pipeR.Close(); err != nil {
+		return err
+	}
+	result.phase = PhaseIdmap
 
 	msgParentHello := &messages.Message{
 		U: messages.U{
-			ParentHello: &messages.ParentHello{},
+			ParentHello: &messages.ParentHello{
+				ParentProtocolVersion:    messages.ProtocolVersion,
+				ParentMinProtocolVersion: messages.MinProtocolVersion,
+				ParentCapabilities:       messages.ParentCapabilities,
+			},
 		},
 	}
 	if err := messages.Send(pipeW, msgParentHello); err != nil {
 		return err
 	}
-	if _, err := messages.WaitFor(pipe2R, messages.Name(messages.ChildHello{})); err != nil {
+	msg, err := waitForChild(pipe2R, messages.Name(messages.ChildHello{}))
+	if err != nil {
+		return err
+	}
+	childHello := msg.U.ChildHello
+	if childHello == nil {
+		// sent from RootlessKit prior to v3.1.0
+		childHello = &messages.ChildHello{}
+	}
+	childPeer, err := messages.NewPeer("child", childHello.ChildProtocolVersion, childHello.ChildMinProtocolVersion, childHello.ChildCapabilities)
+	if err != nil {
 		return err
 	}
+	logrus.Debugf("child: protocol version %d, capabilities %v", childPeer.ProtocolVersion, childPeer.Capabilities)
 
-	if err := setupUIDGIDMap(cmd.Process.Pid, opt.SubidSource); err != nil {
+	idMapInfo, err := setupUIDGIDMap(cmd.Process.Pid, opt)
+	if err != nil {
 		return fmt.Errorf("failed to setup UID/GID map: %w", err)
 	}
+	hookState := hook.State{
+		StateDir: opt.StateDir,
+		ChildPID: cmd.Process.Pid,
+	}
+	if err := hook.Run(context.TODO(), opt.Hooks, hook.PhaseIdmap, hookState); err != nil {
+		return err
+	}
 	msgParentInitIdmapCompleted := &messages.Message{
 		U: messages.U{
 			ParentInitIdmapCompleted: &messages.ParentInitIdmapCompleted{},
@@ -239,28 +483,79 @@ func Parent(opt Opt) error {
 	if err := messages.Send(pipeW, msgParentInitIdmapCompleted); err != nil {
 		return err
 	}
-	if _, err := messages.WaitFor(pipe2R, messages.Name(messages.ChildInitUserNSCompleted{})); err != nil {
+	result.phase = PhaseUserNS
+	if _, err := waitForChild(pipe2R, messages.Name(messages.ChildInitUserNSCompleted{})); err != nil {
 		return err
 	}
 
-	sigc := sigproxy.ForwardAllSignals(context.TODO(), cmd.Process.Pid)
+	childExited := make(chan struct{})
+	stopSignal := opt.StopSignal
+	if stopSignal == 0 {
+		stopSignal = syscall.SIGTERM
+	}
+	// STOPPING=1 is sent when the child is requested to stop, or when the child exited by itself
+	var stoppingOnce sync.Once
+	notifyStopping := func() {
+		stoppingOnce.Do(func() {
+			if err := notifier.Notify(notify.Stopping); err != nil {
+				logrus.WithError(err).Warn("failed to send the stopping notification")
+			}
+		})
+	}
+	stopper := &stopper{
+		pid:     cmd.Process.Pid,
+		signal:  stopSignal,
+		timeout: opt.StopTimeout,
+		exited:  childExited,
+		onStop:  notifyStopping,
+	}
+	// SIGTERM is translated to the stop signal. Other signals are forwarded as-is.
+	sigc := sigproxy.ForwardAllSignalsWithHandler(context.TODO(), cmd.Process.Pid, func(s os.Signal) bool {
+		if s != syscall.SIGTERM {
+			return false
+		}
+		stopper.stop()
+		return true
+	})
 	defer signal.StopCatch(sigc)
 
+	// cgroupDir is the group of the child if created, otherwise the evacuated group that contains the parent and the child
+	var cgroupDir string
 	if opt.EvacuateCgroup2 != "" {
 		if err := cgrouputil.EvacuateCgroup2(opt.EvacuateCgroup2); err != nil {
 			return err
 		}
+		cgroupDir = cgrouputil.Path(cmd.Process.Pid)
+	}
+	if childCgroupDir != "" {
+		// The child is evacuated in its own group too, so that the controllers are enabled for the groups
+		// created in the cgroup namespace. The child sees the same "/<evac>" group as without the group of the child.
+		if err := cgrouputil.EvacuateGroup(childCgroupDir, opt.EvacuateCgroup2); err != nil {
+			return err
+		}
+		cgroupDir = childCgroupDir
+	}
+	if opt.CgroupLimits != nil {
+		// The limits are applied to the group of the child, as the limits of the delegated group itself are not writable.
+		// The target command cannot escape the limits, as the group is the root of the cgroup namespace.
+		if err := cgrouputil.SetLimits(childCgroupDir, opt.CgroupLimits); err != nil {
+			return err
+		}
 	}
 
 	// configure Network driver
+	result.phase = PhaseNetwork
+	if err := notifier.Notify(notify.Status("Configuring the network")); err != nil {
+		logrus.WithError(err).Warn("failed to send the status notification")
+	}
 	msgParentInitNetworkDriverCompleted := &messages.Message{
 		U: messages.U{
 			ParentInitNetworkDriverCompleted: &messages.ParentInitNetworkDriverCompleted{},
 		},
 	}
 
+	var netns string
 	if opt.NetworkDriver != nil {
-		var netns string
 		if opt.DetachNetNS {
 			netns = filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "root", filepath.Clean(opt.StateDir), "netns")
 		}
@@ -272,12 +567,22 @@ func Parent(opt Opt) error {
 			return fmt.Errorf("failed to setup network %+v: %w", opt.NetworkDriver, err)
 		}
 		msgParentInitNetworkDriverCompleted.U.ParentInitNetworkDriverCompleted = netMsg
+		ev := events.Event{Type: events.TypeNetworkConfigured}
+		if info, err := opt.NetworkDriver.Info(context.TODO()); err == nil {
+			ev.Network = info
+		}
+		bus.Publish(ev)
+		watchHelper(opt.NetworkDriver, bus, childExited)
 	}
 	if err := messages.Send(pipeW, msgParentInitNetworkDriverCompleted); err != nil {
 		return err
 	}
 
 	// configure Port driver
+	result.phase = PhasePort
+	if err := notifier.Notify(notify.Status("Configuring the ports")); err != nil {
+		logrus.WithError(err).Warn("failed to send the status notification")
+	}
 	msgParentInitPortDriverCompleted := &messages.Message{
 		U: messages.U{
 			ParentInitPortDriverCompleted: &messages.ParentInitPortDriverCompleted{},
@@ -307,8 +612,12 @@ func Parent(opt Opt) error {
 		return err
 	}
 
-	// Close the parent-to-child pipe
-	if err := pipeW.Close(); err != nil {
+	// Keep the parent-to-child pipe open for the control channel if the child supports it,
+	// otherwise close the pipe
+	var control *controlClient
+	if childPeer.Can(messages.Name(messages.ParentRequest{})) {
+		control = newControlClient(pipeW)
+	} else if err := pipeW.Close(); err != nil {
 		return err
 	}
 	if opt.PortDriver != nil {
@@ -327,128 +636,194 @@ func Parent(opt Opt) error {
 			logrus.Debugf("published port %v", st)
 		}
 	}
+	if err := hook.Run(context.TODO(), opt.Hooks, hook.PhasePorts, hookState); err != nil {
+		return err
+	}
 
+	result.phase = PhaseAPI
 	// after child is fully configured, write PID to child_pid file
 	childPIDPath := filepath.Join(opt.StateDir, StateFileChildPID)
 	if err := os.WriteFile(childPIDPath, []byte(strconv.Itoa(cmd.Process.Pid)), 0444); err != nil {
 		return fmt.Errorf("failed to write the child PID %d to %s: %w", cmd.Process.Pid, childPIDPath, err)
 	}
+	// the child notifies the restarts of the target command, and the error
+	childMsgs := newChildMessages(control)
+	go childMsgs.recv(pipe2R, bus)
 	// listens the API
 	apiSockPath := filepath.Join(opt.StateDir, StateFileAPISock)
-	apiCloser, err := listenServeAPI(apiSockPath, &router.Backend{
-		StateDir:      opt.StateDir,
-		ChildPID:      cmd.Process.Pid,
-		NetworkDriver: opt.NetworkDriver,
-		PortDriver:    opt.PortDriver,
-	})
+	backend := &router.Backend{
+		StateDir:          opt.StateDir,
+		ChildPID:          cmd.Process.Pid,
+		DetachedNetNSPath: netns,
+		ExecEnv:           documentedEnv,
+		NetworkDriver:     opt.NetworkDriver,
+		PortDriver:        opt.PortDriver,
+		IDMap:             idMapInfo,
+		Events:            bus,
+		TargetRestarts:    func() int { return int(childMsgs.targetRestarts.Load()) },
+		Shutdown:          stopper.stop,
+		Cgroup: func() (*api.Cgroup, error) {
+			dir := cgroupDir
+			if dir == "" {
+				dir = cgrouputil.Path(cmd.Process.Pid)
+			}
+			if dir == "" {
+				return nil, errors.New("cgroup2 is not available")
+			}
+			cg, err := cgrouputil.Stat(dir)
+			if err != nil {
+				return nil, err
+			}
+			if opt.CgroupFreezer {
+				cg.Frozen, _ = cgrouputil.Frozen(childCgroupDir)
+			}
+			return cg, nil
+		},
+	}
+	if opt.CgroupFreezer {
+		backend.Freeze = func(ctx context.Context, frozen bool) error {
+			return freeze(ctx, childCgroupDir, frozen, opt.PortDriver, bus)
+		}
+	}
+	if len(opt.CopyUpPersistDirs) != 0 {
+		backend.CopyUpDiff = func() ([]api.CopyUpChange, error) {
+			return copyUpDiff(opt.CopyUpPersistDirs)
+		}
+	}
+	if opt.Landlock {
+		backend.Landlock = func() *api.LandlockInfo {
+			abi := childMsgs.landlockABI.Load()
+			if abi == nil {
+				return nil
+			}
+			return &api.LandlockInfo{ABI: *abi}
+		}
+	}
+	if opt.CreateTimeNS {
+		backend.TimeNamespace = &api.TimeNamespaceInfo{
+			MonotonicOffset: opt.MonotonicOffset,
+			BoottimeOffset:  opt.BoottimeOffset,
+		}
+	}
+	if control != nil {
+		backend.Child = control
+	}
+	if d != nil {
+		backend.Log = d.log
+		backend.Stdin = stdin
+	}
+	apiCloser, err := listenServeAPI(apiSockPath, backend)
 	if err != nil {
 		return err
 	}
-	// block until the child exits
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("child exited: %w", err)
+	result.phase = ""
+	if d != nil {
+		d.notify(nil)
 	}
+	if notifier != nil {
+		go func() {
+			if forwarder != nil {
+				_ = notifier.Notify(notify.Status("Waiting for the child to be ready"))
+				select {
+				case <-forwarder.Ready():
+				case <-childExited:
+					return
+				}
+			}
+			if err := notifier.Notify(notify.Ready, notify.Status(readyStatus(backend))); err != nil {
+				logrus.WithError(err).Warn("failed to send the readiness notification")
+			}
+			if watchdogInterval <= 0 {
+				return
+			}
+			apiClient, err := client.New(apiSockPath)
+			if err != nil {
+				logrus.WithError(err).Warn("failed to create the API client, the watchdog is disabled")
+				return
+			}
+			// the watchdog is started after READY=1, and stopped when the child exited
+			notifier.RunWatchdog(watchdogInterval, childExited, func(ctx context.Context) error {
+				return checkHealth(ctx, apiClient, childExited)
+			})
+		}()
+	}
+	// block until the child exits
+	waitErr := cmd.Wait()
+	close(childExited)
+	result.setProcessState(cmd.ProcessState)
+	bus.Publish(childExitedEvent(cmd.ProcessState))
+	notifyStopping()
+	exitCode := cmd.ProcessState.ExitCode()
+	hookState.ExitCode = &exitCode
+	if err := hook.Run(context.TODO(), opt.Hooks, hook.PhaseExit, hookState); err != nil {
+		logrus.WithError(err).Warn("exit hook failed")
+	}
+	bus.Publish(events.Event{Type: events.TypeShutdown})
+	// close the event streams before closing the API socket
+	bus.Close()
 	// close the API socket
 	if err := apiCloser.Close(); err != nil {
-		return fmt.Errorf("failed to close %s: %w", apiSockPath, err)
+		logrus.WithError(err).Warnf("failed to close %s", apiSockPath)
+	}
+	if err := control.close(); err != nil {
+		logrus.WithError(err).Debug("failed to close the control channel")
 	}
-	// shut down port driver
+	// shut down port driver, and then network driver (deferred)
 	if opt.PortDriver != nil {
 		portDriverQuit <- struct{}{}
 		err = <-portDriverErr
 	}
+	if waitErr != nil {
+		if childErr := childMsgs.childError(); childErr != nil {
+			return fmt.Errorf("child exited: %w: %w", waitErr, childErr)
+		}
+		return fmt.Errorf("child exited: %w", waitErr)
+	}
 	return err
 }
 
-func getSubIDRanges(u *user.User, subidSource SubidSource) ([]idtools.SubIDRange, []idtools.SubIDRange, error) {
-	uid, err := strconv.Atoi(u.Uid)
-	if err != nil {
-		return nil, nil, err
-	}
-	switch subidSource {
-	case SubidSourceStatic:
-		logrus.Debug("subid-source: using the static source")
-		return idtools.GetSubIDRanges(uid, u.Username)
-	case SubidSourceDynamic:
-		logrus.Debug("subid-source: using the dynamic source")
-		return dynidtools.GetSubIDRanges(uid, u.Username)
-	case "", SubidSourceAuto:
-		subuidRanges, subgidRanges, err := getSubIDRanges(u, SubidSourceDynamic)
-		if err == nil && len(subuidRanges) > 0 && len(subgidRanges) > 0 {
-			return subuidRanges, subgidRanges, nil
-		}
-		logrus.WithError(err).Debugf("failed to use subid source %q, falling back to %q", SubidSourceDynamic, SubidSourceStatic)
-		return getSubIDRanges(u, SubidSourceStatic)
-	default:
-		return nil, nil, fmt.Errorf("unknown subid source %q", subidSource)
+// copyUpDiff returns the changes in the persistent copy-up layers.
+// The lower directories are read in the mount namespace of the parent, i.e., the original directories.
+func copyUpDiff(persistDirs map[string]string) ([]api.CopyUpChange, error) {
+	var res []api.CopyUpChange
+	for _, dir := range slices.Sorted(maps.Keys(persistDirs)) {
+		changes, err := overlayfs.Diff(dir, overlayfs.UpperDir(persistDirs[dir]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the diff of %s: %w", dir, err)
+		}
+		res = append(res, changes...)
 	}
+	return res, nil
 }
 
-func newugidmapArgs(subidSource SubidSource) ([]string, []string, error) {
-	u, err := user.Current()
-	if err != nil {
-		return nil, nil, err
+// checkHealth returns nil when the child is running and the API socket answers.
+// checkHealth is used for the sd_notify(3) watchdog.
+func checkHealth(ctx context.Context, c client.Client, childExited <-chan struct{}) error {
+	select {
+	case <-childExited:
+		return errors.New("the child exited")
+	default:
 	}
-	subuidRanges, subgidRanges, err := getSubIDRanges(u, subidSource)
-	if err != nil {
-		return nil, nil, err
+	if _, err := c.Info(ctx); err != nil {
+		return fmt.Errorf("the API socket does not answer: %w", err)
 	}
-	logrus.Debugf("subuid ranges=%v", subuidRanges)
-	logrus.Debugf("subgid ranges=%v", subgidRanges)
-	return newugidmapArgsFromSubIDRanges(u, subuidRanges, subgidRanges)
-}
-
-func newugidmapArgsFromSubIDRanges(u *user.User, subuidRanges, subgidRanges []idtools.SubIDRange) ([]string, []string, error) {
-	uidMap := []string{
-		"0",
-		u.Uid,
-		"1",
-	}
-	gidMap := []string{
-		"0",
-		u.Gid,
-		"1",
-	}
-
-	uidMapLast := 1
-	for _, f := range subuidRanges {
-		uidMap = append(uidMap, []string{
-			strconv.Itoa(uidMapLast),
-			strconv.Itoa(f.Start),
-			strconv.Itoa(f.Length),
-		}...)
-		uidMapLast += f.Length
-	}
-	gidMapLast := 1
-	for _, f := range subgidRanges {
-		gidMap = append(gidMap, []string{
-			strconv.Itoa(gidMapLast),
-			strconv.Itoa(f.Start),
-			strconv.Itoa(f.Length),
-		}...)
-		gidMapLast += f.Length
-	}
-
-	return uidMap, gidMap, nil
+	return nil
 }
 
-func setupUIDGIDMap(pid int, subidSource SubidSource) error {
-	uArgs, gArgs, err := newugidmapArgs(subidSource)
-	if err != nil {
-		return fmt.Errorf("failed to compute uid/gid map: %w", err)
-	}
-	pidS := strconv.Itoa(pid)
-	cmd := exec.Command("newuidmap", append([]string{pidS}, uArgs...)...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("newuidmap %s %v failed: %s: %w", pidS, uArgs, string(out), err)
+// readyStatus returns the STATUS= string for the sd_notify(3) readiness notification.
+func readyStatus(b *router.Backend) string {
+	status := fmt.Sprintf("Ready (child PID=%d", b.ChildPID)
+	if b.NetworkDriver != nil {
+		if info, err := b.NetworkDriver.Info(context.TODO()); err == nil {
+			status += ", network driver=" + info.Driver
+		}
 	}
-	cmd = exec.Command("newgidmap", append([]string{pidS}, gArgs...)...)
-	out, err = cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("newgidmap %s %v failed: %s: %w", pidS, gArgs, string(out), err)
+	if b.PortDriver != nil {
+		if info, err := b.PortDriver.Info(context.TODO()); err == nil {
+			status += ", port driver=" + info.Driver
+		}
 	}
-	return nil
+	return status + ")"
 }
 
 // apiCloser is implemented by *http.Server
@@ -1,11 +1,20 @@
 package parent
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"os/user"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/rootless-containers/rootlesskit/v3/pkg/events"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/messages"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/parent/idtools"
+	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
 	"golang.org/x/sys/unix"
 	"gotest.tools/v3/assert"
 )
@@ -40,7 +49,7 @@ func TestNewugidmapArgsFromSubIDRanges(t *testing.T) {
 	}
 	u, err := user.Current()
 	assert.NilError(t, err)
-	newuidmapArgs, newgidmapArgs, err := newugidmapArgsFromSubIDRanges(u, subuidRanges, subgidRanges)
+	newuidmapArgs, newgidmapArgs, err := newugidmapArgsFromSubIDRanges(u, subuidRanges, subgidRanges, nil, nil, 0)
 	assert.NilError(t, err)
 	expectedU := []string{
 		"0", u.Uid, "1", "1", "100000", "65536", "65537", "200000", "65536",
@@ -51,3 +60,147 @@ func TestNewugidmapArgsFromSubIDRanges(t *testing.T) {
 	assert.DeepEqual(t, expectedU, newuidmapArgs)
 	assert.DeepEqual(t, expectedG, newgidmapArgs)
 }
+
+func TestWriteResult(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "result.json")
+	code := 42
+	r := &Result{
+		StartedAt:   time.Now(),
+		FinishedAt:  time.Now(),
+		ExitCode:    &code,
+		FailedPhase: PhaseNetwork,
+		Error:       "foo",
+	}
+	assert.NilError(t, writeResult(p, r))
+	b, err := os.ReadFile(p)
+	assert.NilError(t, err)
+	var r2 Result
+	assert.NilError(t, json.Unmarshal(b, &r2))
+	assert.Equal(t, 42, *r2.ExitCode)
+	assert.Equal(t, PhaseNetwork, r2.FailedPhase)
+	assert.Equal(t, "foo", r2.Error)
+	assert.Assert(t, r2.StartedAt.Equal(r.StartedAt))
+}
+
+func TestWaitForChildError(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NilError(t, err)
+	defer r.Close()
+	defer w.Close()
+	msg := &messages.Message{
+		U: messages.U{
+			ChildError: &messages.ChildError{
+				Phase:        string(PhaseCopyUp),
+				ErrorMessage: "failed to copy up /etc: permission denied",
+				Errno:        int(unix.EACCES),
+			},
+		},
+	}
+	assert.NilError(t, messages.Send(w, msg))
+	_, err = waitForChild(r, messages.Name(messages.ChildInitUserNSCompleted{}))
+	var childErr *ChildError
+	assert.Assert(t, errors.As(err, &childErr))
+	assert.Equal(t, PhaseCopyUp, childErr.Phase)
+	assert.Assert(t, errors.Is(err, unix.EACCES))
+}
+
+func TestControlClient(t *testing.T) {
+	reqR, reqW, err := os.Pipe()
+	assert.NilError(t, err)
+	defer reqR.Close()
+	respR, respW, err := os.Pipe()
+	assert.NilError(t, err)
+	control := newControlClient(reqW)
+	cm := newChildMessages(control)
+	go cm.recv(respR, nil)
+
+	// fake child
+	go func() {
+		defer respW.Close()
+		msg, err := messages.WaitFor(reqR, messages.Name(messages.ParentRequest{}))
+		if err != nil {
+			return
+		}
+		resp := &messages.Message{
+			U: messages.U{
+				ChildResponse: &messages.ChildResponse{
+					ResponseID:     msg.U.ParentRequest.RequestID,
+					ResponseResult: []byte(`{"Value":"42"}`),
+				},
+			},
+		}
+		_ = messages.Send(respW, resp)
+	}()
+
+	v, err := control.Sysctl(context.TODO(), "foo.bar", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, "42", v)
+
+	// the channel is closed when the child exits
+	<-cm.done
+	_, err = control.Sysctl(context.TODO(), "foo.bar", nil)
+	assert.ErrorIs(t, err, errControlClosed)
+}
+
+func TestChildMessagesLandlockApplied(t *testing.T) {
+	var buf bytes.Buffer
+	cm := newChildMessages(nil)
+	assert.Assert(t, cm.landlockABI.Load() == nil)
+	msg := &messages.Message{
+		U: messages.U{
+			ChildLandlockApplied: &messages.ChildLandlockApplied{
+				LandlockABI: 6,
+			},
+		},
+	}
+	assert.NilError(t, messages.Send(&buf, msg))
+	cm.recv(&buf, nil)
+	abi := cm.landlockABI.Load()
+	assert.Assert(t, abi != nil)
+	assert.Equal(t, 6, *abi)
+}
+
+type fakePauserPortDriver struct {
+	port.ParentDriver
+	paused bool
+}
+
+func (d *fakePauserPortDriver) Pause() {
+	d.paused = true
+}
+
+func (d *fakePauserPortDriver) Resume() {
+	d.paused = false
+}
+
+func TestFreezePausesWrappedPortDriver(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte("0\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte("frozen 1\n"), 0644))
+	bus := events.NewBus()
+	defer bus.Close()
+	fake := &fakePauserPortDriver{}
+	// wrapped as in runParent
+	driver := newEventPortDriver(fake, bus)
+	assert.NilError(t, freeze(context.TODO(), dir, true, driver, bus))
+	assert.Check(t, fake.paused)
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.events"), []byte("frozen 0\n"), 0644))
+	assert.NilError(t, freeze(context.TODO(), dir, false, driver, bus))
+	assert.Check(t, !fake.paused)
+}
+
+func TestFreezeRejectsUnpausablePortDriver(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte("0\n"), 0644))
+	bus := events.NewBus()
+	defer bus.Close()
+	driver := newEventPortDriver(struct{ port.ParentDriver }{}, bus)
+	_, ok := driver.(port.Pauser)
+	assert.Check(t, !ok)
+	assert.ErrorContains(t, freeze(context.TODO(), dir, true, driver, bus), "cannot refuse new connections")
+	// not frozen
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	assert.NilError(t, err)
+	assert.Equal(t, "0\n", string(b))
+}
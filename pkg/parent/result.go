@@ -0,0 +1,80 @@
+package parent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Phase is the initialization phase.
+// The phases of the child are reported via ChildError.
+type Phase string
+
+const (
+	PhaseStart   = Phase("start")   // creating the state directory and starting the child
+	PhaseIdmap   = Phase("idmap")   // writing the uid_map and the gid_map of the child
+	PhaseUserNS  = Phase("userns")  // initializing the user namespace in the child
+	PhaseNetwork = Phase("network") // configuring the network driver, in the parent and the child
+	PhasePort    = Phase("port")    // configuring the port driver and publishing the ports, in the parent and the child
+	PhaseAPI     = Phase("api")     // starting the API
+	PhaseMount   = Phase("mount")   // setting up the mount propagation and mounting sysfs, in the child
+	PhaseCopyUp  = Phase("copy-up") // setting up the copy-up directories, in the child
+	PhaseExec    = Phase("exec")    // executing the target command, in the child
+)
+
+// Result is written to Opt.ResultFile as JSON, when the parent exits.
+type Result struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// ExitCode is the exit code of the child, set only when the child was waited.
+	// ExitCode is -1 when the child was killed by a signal.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Signal is the signal that killed the child.
+	Signal string `json:"signal,omitempty"`
+	// FailedPhase is the initialization phase that failed.
+	// FailedPhase is empty when the initialization succeeded.
+	FailedPhase Phase `json:"failedPhase,omitempty"`
+	// Error is the error message.
+	Error string `json:"error,omitempty"`
+
+	// phase is the current initialization phase, empty after the initialization.
+	phase Phase
+}
+
+// setProcessState sets ExitCode and Signal.
+func (r *Result) setProcessState(st *os.ProcessState) {
+	if st == nil {
+		return
+	}
+	code := st.ExitCode()
+	r.ExitCode = &code
+	if ws, ok := st.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		r.Signal = ws.Signal().String()
+	}
+}
+
+// writeResult writes the result file atomically.
+func writeResult(p string, r *Result) error {
+	b, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
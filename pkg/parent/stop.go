@@ -0,0 +1,48 @@
+package parent
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stopper stops the child gracefully.
+type stopper struct {
+	pid     int
+	signal  syscall.Signal
+	timeout time.Duration // 0 for no timeout
+	exited  <-chan struct{}
+	onStop  func() // optional, called before sending the stop signal
+	once    sync.Once
+}
+
+// stop sends the stop signal to the child, and sends SIGKILL when the child does not exit within the timeout.
+// stop is no-op when called twice.
+func (s *stopper) stop() {
+	s.once.Do(func() {
+		if s.onStop != nil {
+			s.onStop()
+		}
+		logrus.Debugf("Stopping the child (PID=%d) with %v", s.pid, s.signal)
+		// ESRCH is ignored, as the child may have exited already
+		if err := syscall.Kill(s.pid, s.signal); err != nil && !errors.Is(err, syscall.ESRCH) {
+			logrus.WithError(err).Warnf("Error sending signal %v", s.signal)
+		}
+		if s.timeout <= 0 {
+			return
+		}
+		go func() {
+			select {
+			case <-s.exited:
+			case <-time.After(s.timeout):
+				logrus.Warnf("The child (PID=%d) did not exit in %v, sending SIGKILL", s.pid, s.timeout)
+				if err := syscall.Kill(s.pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+					logrus.WithError(err).Warn("Error sending SIGKILL")
+				}
+			}
+		}()
+	})
+}
@@ -0,0 +1,74 @@
+package parent
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// startSleep starts `sleep` and returns the channel closed on its exit.
+func startSleep(t *testing.T) (*exec.Cmd, <-chan struct{}) {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	assert.NilError(t, cmd.Start())
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		<-exited
+	})
+	return cmd, exited
+}
+
+func exitSignal(t *testing.T, cmd *exec.Cmd) syscall.Signal {
+	t.Helper()
+	ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	assert.Assert(t, ok)
+	assert.Assert(t, ws.Signaled())
+	return ws.Signal()
+}
+
+func waitExited(exited <-chan struct{}, timeout time.Duration) error {
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("timed out")
+	}
+}
+
+func TestStopperSignal(t *testing.T) {
+	cmd, exited := startSleep(t)
+	var onStopCalled int
+	s := &stopper{pid: cmd.Process.Pid, signal: syscall.SIGTERM, timeout: time.Minute, exited: exited,
+		onStop: func() { onStopCalled++ }}
+	s.stop()
+	// no-op
+	s.stop()
+	assert.NilError(t, waitExited(exited, 10*time.Second))
+	assert.Equal(t, syscall.SIGTERM, exitSignal(t, cmd))
+	assert.Equal(t, 1, onStopCalled)
+}
+
+func TestStopperEscalation(t *testing.T) {
+	cmd, exited := startSleep(t)
+	// sleep does not exit on SIGCONT
+	s := &stopper{pid: cmd.Process.Pid, signal: syscall.SIGCONT, timeout: 100 * time.Millisecond, exited: exited}
+	s.stop()
+	assert.NilError(t, waitExited(exited, 10*time.Second))
+	assert.Equal(t, syscall.SIGKILL, exitSignal(t, cmd))
+}
+
+func TestStopperNoTimeout(t *testing.T) {
+	cmd, exited := startSleep(t)
+	s := &stopper{pid: cmd.Process.Pid, signal: syscall.SIGCONT, exited: exited}
+	s.stop()
+	assert.ErrorContains(t, waitExited(exited, 500*time.Millisecond), "timed out")
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -56,6 +57,7 @@ type driver struct {
 	ports               map[int]*port.Status
 	stoppers            map[int]func(context.Context) error
 	nextID              int
+	paused              atomic.Bool
 }
 
 func (d *driver) Info(ctx context.Context) (*api.PortDriverInfo, error) {
@@ -78,6 +80,17 @@ func (d *driver) OpaqueForChild() map[string]string {
 	return m
 }
 
+// Pause implements port.Pauser.
+// New TCP connections are closed, and UDP datagrams are dropped.
+func (d *driver) Pause() {
+	d.paused.Store(true)
+}
+
+// Resume implements port.Pauser.
+func (d *driver) Resume() {
+	d.paused.Store(false)
+}
+
 func (d *driver) RunParentDriver(initComplete chan struct{}, quit <-chan struct{}, _ *port.ChildContext) error {
 	childReadyPipeR, err := os.OpenFile(d.childReadyPipePath, os.O_RDONLY, os.ModeNamedPipe)
 	if err != nil {
@@ -161,9 +174,9 @@ func (d *driver) AddPort(ctx context.Context, spec port.Spec) (*port.Status, err
 	}
 	switch spec.Proto {
 	case "tcp", "tcp4", "tcp6":
-		err = tcp.Run(d.socketPath, spec, routineStopCh, routineStoppedCh, d.logWriter)
+		err = tcp.Run(d.socketPath, spec, routineStopCh, routineStoppedCh, &d.paused, d.logWriter)
 	case "udp", "udp4", "udp6":
-		err = udp.Run(d.socketPath, spec, routineStopCh, routineStoppedCh, d.logWriter)
+		err = udp.Run(d.socketPath, spec, routineStopCh, routineStoppedCh, &d.paused, d.logWriter)
 	default:
 		return nil, fmt.Errorf("unsupported port protocol %s", spec.Proto)
 	}
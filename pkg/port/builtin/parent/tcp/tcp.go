@@ -7,12 +7,15 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port"
 	"github.com/rootless-containers/rootlesskit/v3/pkg/port/builtin/msg"
 )
 
-func Run(socketPath string, spec port.Spec, stopCh <-chan struct{}, stoppedCh chan error, logWriter io.Writer) error {
+// Run runs the proxy for the spec.
+// New connections are closed immediately while paused is true.
+func Run(socketPath string, spec port.Spec, stopCh <-chan struct{}, stoppedCh chan error, paused *atomic.Bool, logWriter io.Writer) error {
 	ln, err := net.Listen(spec.Proto, net.JoinHostPort(spec.ParentIP, strconv.Itoa(spec.ParentPort)))
 	if err != nil {
 		fmt.Fprintf(logWriter, "listen: %v\n", err)
@@ -41,6 +44,11 @@ func Run(socketPath string, spec port.Spec, stopCh <-chan struct{}, stoppedCh ch
 				if !ok {
 					return
 				}
+				if paused.Load() {
+					fmt.Fprintf(logWriter, "closing connection from %v, as the child is frozen\n", c.RemoteAddr())
+					c.Close()
+					continue
+				}
 				go func() {
 					if err := copyConnToChild(c, socketPath, spec, stopCh); err != nil {
 						fmt.Fprintf(logWriter, "copyConnToChild: %v\n", err)
@@ -8,6 +8,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -50,6 +51,7 @@ type UDPProxy struct {
 	LogWriter      io.Writer
 	Listener       *net.UDPConn
 	BackendDial    func() (*net.UDPConn, error)
+	Paused         *atomic.Bool // optional, datagrams from the clients are dropped while true
 	connTrackTable connTrackMap
 	connTrackLock  sync.Mutex
 }
@@ -103,6 +105,9 @@ func (proxy *UDPProxy) Run() {
 			}
 			break
 		}
+		if proxy.Paused != nil && proxy.Paused.Load() {
+			continue
+		}
 
 		fromKey := newConnTrackKey(from)
 		proxy.connTrackLock.Lock()
@@ -67,6 +67,17 @@ type ParentDriver interface {
 	RunParentDriver(initComplete chan struct{}, quit <-chan struct{}, cctx *ChildContext) error
 }
 
+// Pauser is an optional interface of ParentDriver (since v3.1.0).
+// Pauser is used for refusing new connections while the child is frozen.
+// The child cannot be frozen with a ParentDriver that does not implement Pauser.
+type Pauser interface {
+	// Pause makes the driver close new connections immediately, and drop datagrams.
+	// The established connections are kept.
+	Pause()
+	// Resume makes the driver accept new connections again.
+	Resume()
+}
+
 type ChildDriver interface {
 	// RunChildDriver is executed in the child's namespaces, excluding detached-netns.
 	RunChildDriver(opaque map[string]string, quit <-chan struct{}, detachedNetNSPath string) error
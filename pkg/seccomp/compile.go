@@ -0,0 +1,334 @@
+package seccomp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// offsets in struct seccomp_data
+	offNR   = 0
+	offArch = 4
+	offArgs = 16
+
+	// bpfMaxInsns is BPF_MAXINSNS of Linux.
+	bpfMaxInsns = 4096
+
+	// x32SyscallBit is __X32_SYSCALL_BIT of x86_64.
+	x32SyscallBit = 0x40000000
+)
+
+// Compile compiles the profile into a BPF program for the native architecture.
+//
+// The rules for the syscalls that are unknown to the native architecture are skipped,
+// so such syscalls are handled by the default action.
+// The rules are evaluated in the order of the profile.
+//
+// caps is the capabilities of the target command, e.g., "CAP_SYS_ADMIN",
+// for evaluating "includes" and "excludes" of the Docker profile.
+func Compile(p *Profile, caps []string) ([]unix.SockFilter, error) {
+	if syscalls == nil {
+		return nil, fmt.Errorf("seccomp is not supported on %s", runtime.GOARCH)
+	}
+	defaultRet, err := actionRet(p.DefaultAction, p.DefaultErrnoRet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default action: %w", err)
+	}
+	kernel, err := kernelVersion()
+	if err != nil {
+		return nil, err
+	}
+	enosys := unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)
+	prog := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nativeAuditArch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, enosys),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offNR),
+	}
+	if nativeAuditArch == unix.AUDIT_ARCH_X86_64 {
+		prog = append(prog,
+			jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+			stmt(unix.BPF_RET|unix.BPF_K, enosys),
+		)
+	}
+	for i, sc := range p.Syscalls {
+		if !sc.Includes.includes(kernel, caps) || sc.Excludes.excludes(kernel, caps) {
+			continue
+		}
+		ret, err := actionRet(sc.Action, sc.ErrnoRet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid action of syscalls[%d]: %w", i, err)
+		}
+		if ret == defaultRet {
+			continue
+		}
+		names := sc.Names
+		if sc.Name != "" {
+			names = append(names, sc.Name)
+		}
+		for _, name := range names {
+			nr, ok := syscalls[name]
+			if !ok {
+				continue
+			}
+			for _, args := range splitArgs(sc.Args) {
+				block, err := compileRule(nr, args, ret)
+				if err != nil {
+					return nil, fmt.Errorf("failed to compile the rule for %q: %w", name, err)
+				}
+				prog = append(prog, block...)
+			}
+		}
+	}
+	prog = append(prog, stmt(unix.BPF_RET|unix.BPF_K, defaultRet))
+	if len(prog) > bpfMaxInsns {
+		return nil, fmt.Errorf("too many instructions (%d > %d)", len(prog), bpfMaxInsns)
+	}
+	return prog, nil
+}
+
+func actionRet(action Action, errnoRet *uint) (uint32, error) {
+	errno := uint32(unix.EPERM)
+	if errnoRet != nil {
+		if *errnoRet > unix.SECCOMP_RET_DATA {
+			return 0, fmt.Errorf("invalid errno %d", *errnoRet)
+		}
+		errno = uint32(*errnoRet)
+	}
+	switch action {
+	case ActKill, ActKillThread:
+		return unix.SECCOMP_RET_KILL_THREAD, nil
+	case ActKillProcess:
+		return unix.SECCOMP_RET_KILL_PROCESS, nil
+	case ActTrap:
+		return unix.SECCOMP_RET_TRAP, nil
+	case ActErrno:
+		return unix.SECCOMP_RET_ERRNO | errno, nil
+	case ActTrace:
+		return unix.SECCOMP_RET_TRACE | errno, nil
+	case ActAllow:
+		return unix.SECCOMP_RET_ALLOW, nil
+	case ActLog:
+		return unix.SECCOMP_RET_LOG, nil
+	default:
+		return 0, fmt.Errorf("unsupported action %q", action)
+	}
+}
+
+// includes returns whether all the caps, the arches, and the minimum kernel version of the filter match the current environment.
+// includes returns true for a nil filter.
+func (f *Filter) includes(kernel [2]int, caps []string) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.Caps {
+		if !slices.Contains(caps, c) {
+			return false
+		}
+	}
+	if len(f.Arches) != 0 && !slices.Contains(f.Arches, runtime.GOARCH) {
+		return false
+	}
+	if f.MinKernel != "" {
+		minKernel, err := parseKernelVersion(f.MinKernel)
+		if err != nil || compareKernelVersion(kernel, minKernel) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// excludes returns whether any of the caps, the arches, and the minimum kernel version of the filter matches
+// the current environment, as in moby.
+// excludes returns false for a nil filter.
+func (f *Filter) excludes(kernel [2]int, caps []string) bool {
+	if f == nil {
+		return false
+	}
+	for _, c := range f.Caps {
+		if slices.Contains(caps, c) {
+			return true
+		}
+	}
+	if slices.Contains(f.Arches, runtime.GOARCH) {
+		return true
+	}
+	if f.MinKernel != "" {
+		minKernel, err := parseKernelVersion(f.MinKernel)
+		if err == nil && compareKernelVersion(kernel, minKernel) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// splitArgs splits the conditions into multiple rules, when the conditions are specified
+// multiple times for the same argument, as in runc.
+// Otherwise the conditions are evaluated as a single rule.
+func splitArgs(args []Arg) [][]Arg {
+	seen := make(map[uint]bool)
+	for _, a := range args {
+		if seen[a.Index] {
+			res := make([][]Arg, len(args))
+			for i := range args {
+				res[i] = args[i : i+1]
+			}
+			return res
+		}
+		seen[a.Index] = true
+	}
+	return [][]Arg{args}
+}
+
+// insn is an instruction with symbolic jumps to the end of the rule.
+type insn struct {
+	unix.SockFilter
+	jtFail bool
+	jfFail bool
+}
+
+// compileRule compiles the rule for a syscall.
+// The accumulator is expected to have the syscall number, and is kept after the rule.
+func compileRule(nr uint32, args []Arg, ret uint32) ([]unix.SockFilter, error) {
+	var body []insn
+	for _, a := range args {
+		cond, err := compileArg(a)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, cond...)
+	}
+	skip := len(body) + 1 // skip the body and the return
+	if len(args) != 0 {
+		skip++ // skip reloading the syscall number too
+	}
+	if skip > 255 {
+		return nil, errors.New("too many conditions")
+	}
+	res := []unix.SockFilter{
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, uint8(skip)),
+	}
+	for i, in := range body {
+		// the conditions jump to the reload of the syscall number on failure
+		toFail := uint8(len(body) - i)
+		if in.jtFail {
+			in.Jt = toFail
+		}
+		if in.jfFail {
+			in.Jf = toFail
+		}
+		res = append(res, in.SockFilter)
+	}
+	res = append(res, stmt(unix.BPF_RET|unix.BPF_K, ret))
+	if len(args) != 0 {
+		res = append(res, stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offNR))
+	}
+	return res, nil
+}
+
+// compileArg compiles the condition for a 64-bit argument.
+// The instructions fall through on success.
+func compileArg(a Arg) ([]insn, error) {
+	if a.Index >= 6 {
+		return nil, fmt.Errorf("invalid argument index %d", a.Index)
+	}
+	off := uint32(offArgs + 8*a.Index)
+	offLo, offHi := off, off+4
+	if nativeBigEndian {
+		offLo, offHi = off+4, off
+	}
+	hi, lo := uint32(a.Value>>32), uint32(a.Value)
+	ldHi := insn{SockFilter: stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offHi)}
+	ldLo := insn{SockFilter: stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offLo)}
+	jmp := func(op uint16, k uint32, jt, jf uint8, jtFail, jfFail bool) insn {
+		return insn{
+			SockFilter: jump(unix.BPF_JMP|op|unix.BPF_K, k, jt, jf),
+			jtFail:     jtFail,
+			jfFail:     jfFail,
+		}
+	}
+	switch a.Op {
+	case OpEqualTo:
+		return []insn{
+			ldHi, jmp(unix.BPF_JEQ, hi, 0, 0, false, true),
+			ldLo, jmp(unix.BPF_JEQ, lo, 0, 0, false, true),
+		}, nil
+	case OpNotEqual:
+		return []insn{
+			ldHi, jmp(unix.BPF_JEQ, hi, 0, 2, false, false),
+			ldLo, jmp(unix.BPF_JEQ, lo, 0, 0, true, false),
+		}, nil
+	case OpMaskedEqual:
+		hi2, lo2 := uint32(a.ValueTwo>>32), uint32(a.ValueTwo)
+		return []insn{
+			ldHi, {SockFilter: stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, hi)}, jmp(unix.BPF_JEQ, hi2, 0, 0, false, true),
+			ldLo, {SockFilter: stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, lo)}, jmp(unix.BPF_JEQ, lo2, 0, 0, false, true),
+		}, nil
+	case OpGreaterThan, OpGreaterEqual:
+		op := uint16(unix.BPF_JGT)
+		if a.Op == OpGreaterEqual {
+			op = unix.BPF_JGE
+		}
+		return []insn{
+			ldHi, jmp(unix.BPF_JGT, hi, 3, 0, false, false), jmp(unix.BPF_JEQ, hi, 0, 0, false, true),
+			ldLo, jmp(op, lo, 0, 0, false, true),
+		}, nil
+	case OpLessThan, OpLessEqual:
+		op := uint16(unix.BPF_JGE)
+		if a.Op == OpLessEqual {
+			op = unix.BPF_JGT
+		}
+		return []insn{
+			ldHi, jmp(unix.BPF_JGT, hi, 0, 0, true, false), jmp(unix.BPF_JEQ, hi, 0, 2, false, false),
+			ldLo, jmp(op, lo, 0, 0, true, false),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", a.Op)
+	}
+}
+
+func stmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func jump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+func kernelVersion() ([2]int, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return [2]int{}, err
+	}
+	return parseKernelVersion(unix.ByteSliceToString(uts.Release[:]))
+}
+
+// parseKernelVersion parses "MAJOR.MINOR[.PATCH][-SUFFIX]".
+func parseKernelVersion(s string) ([2]int, error) {
+	var v [2]int
+	fields := strings.SplitN(s, ".", 3)
+	if len(fields) < 2 {
+		return v, fmt.Errorf("invalid kernel version %q", s)
+	}
+	for i := range v {
+		n, err := strconv.Atoi(strings.SplitN(fields[i], "-", 2)[0])
+		if err != nil {
+			return v, fmt.Errorf("invalid kernel version %q: %w", s, err)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func compareKernelVersion(a, b [2]int) int {
+	if a[0] != b[0] {
+		return a[0] - b[0]
+	}
+	return a[1] - b[1]
+}
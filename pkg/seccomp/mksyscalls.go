@@ -0,0 +1,79 @@
+//go:build ignore
+
+// mksyscalls generates the syscall tables from the SYS_* constants of golang.org/x/sys/unix.
+//
+// Usage: go generate ./pkg/seccomp
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// arches corresponds to hack/make-cross.sh
+var arches = []struct {
+	goarch    string
+	auditArch string
+	bigEndian bool
+}{
+	{"amd64", "AUDIT_ARCH_X86_64", false},
+	{"arm64", "AUDIT_ARCH_AARCH64", false},
+	{"s390x", "AUDIT_ARCH_S390X", true},
+	{"ppc64le", "AUDIT_ARCH_PPC64LE", false},
+	{"riscv64", "AUDIT_ARCH_RISCV64", false},
+	{"arm", "AUDIT_ARCH_ARM", false},
+}
+
+var sysRegexp = regexp.MustCompile(`^\s+SYS_([A-Z0-9_]+)\s+=\s+(\d+)`)
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "golang.org/x/sys").Output()
+	if err != nil {
+		panic(err)
+	}
+	dir := filepath.Join(strings.TrimSpace(string(out)), "unix")
+	for _, a := range arches {
+		if err := generate(dir, a.goarch, a.auditArch, a.bigEndian); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func generate(dir, goarch, auditArch string, bigEndian bool) error {
+	f, err := os.Open(filepath.Join(dir, "zsysnum_linux_"+goarch+".go"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by mksyscalls.go; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "//go:build linux && %s\n\n", goarch)
+	fmt.Fprintf(&b, "package seccomp\n\n")
+	fmt.Fprintf(&b, "import \"golang.org/x/sys/unix\"\n\n")
+	fmt.Fprintf(&b, "const (\nnativeAuditArch = unix.%s\nnativeBigEndian = %v\n)\n\n", auditArch, bigEndian)
+	fmt.Fprintf(&b, "var syscalls = map[string]uint32{\n")
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		m := sysRegexp.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%q: %s,\n", strings.ToLower(m[1]), m[2])
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("zsyscalls_linux_"+goarch+".go", src, 0644)
+}
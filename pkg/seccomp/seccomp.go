@@ -0,0 +1,133 @@
+// Package seccomp loads seccomp profiles of the OCI/Docker JSON format, without depending on libseccomp.
+//
+// Only the native architecture is supported.
+// The syscalls of the other architectures (e.g., i386 syscalls on x86_64) and the x32 syscalls
+// fail with ENOSYS, regardless of the profile.
+package seccomp
+
+//go:generate go run mksyscalls.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Builtin is the name of the built-in profile.
+const Builtin = "builtin"
+
+//go:embed default.json
+var defaultProfileJSON []byte
+
+type Action string
+
+const (
+	ActKill        = Action("SCMP_ACT_KILL") // same as ActKillThread
+	ActKillProcess = Action("SCMP_ACT_KILL_PROCESS")
+	ActKillThread  = Action("SCMP_ACT_KILL_THREAD")
+	ActTrap        = Action("SCMP_ACT_TRAP")
+	ActErrno       = Action("SCMP_ACT_ERRNO")
+	ActTrace       = Action("SCMP_ACT_TRACE")
+	ActAllow       = Action("SCMP_ACT_ALLOW")
+	ActLog         = Action("SCMP_ACT_LOG")
+)
+
+type Operator string
+
+const (
+	OpNotEqual     = Operator("SCMP_CMP_NE")
+	OpLessThan     = Operator("SCMP_CMP_LT")
+	OpLessEqual    = Operator("SCMP_CMP_LE")
+	OpEqualTo      = Operator("SCMP_CMP_EQ")
+	OpGreaterEqual = Operator("SCMP_CMP_GE")
+	OpGreaterThan  = Operator("SCMP_CMP_GT")
+	OpMaskedEqual  = Operator("SCMP_CMP_MASKED_EQ")
+)
+
+// Profile is the seccomp profile.
+// Profile is compatible with the "seccomp" object of the OCI runtime spec, and with the Docker profile.
+type Profile struct {
+	DefaultAction   Action         `json:"defaultAction"`
+	DefaultErrnoRet *uint          `json:"defaultErrnoRet,omitempty"`
+	Architectures   []string       `json:"architectures,omitempty"` // ignored
+	ArchMap         []Architecture `json:"archMap,omitempty"`       // ignored
+	Flags           []string       `json:"flags,omitempty"`         // ignored
+	Syscalls        []Syscall      `json:"syscalls,omitempty"`
+}
+
+// Architecture is used in the Docker profile.
+type Architecture struct {
+	Arch      string   `json:"architecture"`
+	SubArches []string `json:"subArchitectures"`
+}
+
+type Syscall struct {
+	Name     string   `json:"name,omitempty"` // used in old Docker profiles
+	Names    []string `json:"names,omitempty"`
+	Action   Action   `json:"action"`
+	ErrnoRet *uint    `json:"errnoRet,omitempty"`
+	Args     []Arg    `json:"args,omitempty"`
+	// Includes and Excludes are used in the Docker profile
+	Includes *Filter `json:"includes,omitempty"`
+	Excludes *Filter `json:"excludes,omitempty"`
+}
+
+type Arg struct {
+	Index    uint     `json:"index"`
+	Value    uint64   `json:"value"`
+	ValueTwo uint64   `json:"valueTwo,omitempty"`
+	Op       Operator `json:"op"`
+}
+
+// Filter is used in the Docker profile.
+type Filter struct {
+	Caps      []string `json:"caps,omitempty"`
+	Arches    []string `json:"arches,omitempty"`
+	MinKernel string   `json:"minKernel,omitempty"`
+}
+
+// LoadProfile loads the profile from the JSON file.
+// When p is Builtin, the built-in profile is loaded.
+func LoadProfile(p string) (*Profile, error) {
+	b := defaultProfileJSON
+	if p != Builtin {
+		var err error
+		b, err = os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var profile Profile
+	if err := json.Unmarshal(b, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse the seccomp profile %q: %w", p, err)
+	}
+	return &profile, nil
+}
+
+// Apply applies the filter to all the threads of the current process.
+// The filter is inherited to the processes executed from the current process.
+//
+// Apply requires CAP_SYS_ADMIN, or PR_SET_NO_NEW_PRIVS to be set.
+func Apply(filter []unix.SockFilter) error {
+	if len(filter) == 0 {
+		return nil
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	// TSYNC is needed, as the Go runtime may execute the target command from another thread
+	tid, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("failed to load the seccomp filter: %w", errno)
+	}
+	if tid != 0 {
+		return fmt.Errorf("failed to synchronize the seccomp filter with thread %d", tid)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package seccomp
+
+import (
+	"encoding/binary"
+	"runtime"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+// run runs the filter with x/net/bpf.VM, which loads the words in big endian.
+func run(t *testing.T, filter []unix.SockFilter, arch uint32, nr string, args ...uint64) uint32 {
+	t.Helper()
+	insns := make([]bpf.Instruction, len(filter))
+	for i, f := range filter {
+		insns[i] = bpf.RawInstruction{Op: f.Code, Jt: f.Jt, Jf: f.Jf, K: f.K}.Disassemble()
+	}
+	vm, err := bpf.NewVM(insns)
+	assert.NilError(t, err)
+	data := make([]byte, 64)
+	binary.BigEndian.PutUint32(data[offNR:], syscalls[nr])
+	binary.BigEndian.PutUint32(data[offArch:], arch)
+	for i, a := range args {
+		off := offArgs + 8*i
+		offLo, offHi := off, off+4
+		if nativeBigEndian {
+			offLo, offHi = off+4, off
+		}
+		binary.BigEndian.PutUint32(data[offHi:], uint32(a>>32))
+		binary.BigEndian.PutUint32(data[offLo:], uint32(a))
+	}
+	ret, err := vm.Run(data)
+	assert.NilError(t, err)
+	return uint32(ret)
+}
+
+func TestCompileBuiltin(t *testing.T) {
+	p, err := LoadProfile(Builtin)
+	assert.NilError(t, err)
+	filter, err := Compile(p, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ALLOW), run(t, filter, nativeAuditArch, "read"))
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM)), run(t, filter, nativeAuditArch, "keyctl"))
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)), run(t, filter, 0x12345678, "read"))
+}
+
+func TestCompileArgs(t *testing.T) {
+	const big = 0x100000002
+	errno := uint(unix.EACCES)
+	p := &Profile{
+		DefaultAction: ActAllow,
+		Syscalls: []Syscall{
+			{
+				Names:    []string{"read", "not_a_syscall"},
+				Action:   ActErrno,
+				ErrnoRet: &errno,
+				Args:     []Arg{{Index: 0, Value: big, Op: OpGreaterThan}, {Index: 2, Value: 0xff, ValueTwo: 0x0f, Op: OpMaskedEqual}},
+			},
+			{
+				Names:  []string{"write"},
+				Action: ActKillProcess,
+				Args:   []Arg{{Index: 1, Value: big, Op: OpLessEqual}, {Index: 1, Value: 42, Op: OpEqualTo}},
+			},
+			{
+				Names:  []string{"close"},
+				Action: ActAllow, // same as the default action
+			},
+			{
+				Names:    []string{"mount"},
+				Action:   ActErrno,
+				Excludes: &Filter{Caps: []string{"CAP_SYS_ADMIN"}},
+			},
+			{
+				Names:    []string{"fchown"},
+				Action:   ActErrno,
+				Includes: &Filter{Caps: []string{"CAP_SYS_ADMIN"}},
+			},
+		},
+	}
+	filter, err := Compile(p, []string{"CAP_SYS_ADMIN"})
+	assert.NilError(t, err)
+	denied := unix.SECCOMP_RET_ERRNO | uint32(unix.EACCES)
+	allowed := uint32(unix.SECCOMP_RET_ALLOW)
+	killed := uint32(unix.SECCOMP_RET_KILL_PROCESS)
+	assert.Equal(t, denied, run(t, filter, nativeAuditArch, "read", big+1, 0, 0x0f))
+	assert.Equal(t, denied, run(t, filter, nativeAuditArch, "read", 0x200000000, 0, 0x0f))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "read", big, 0, 0x0f))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "read", 0xffffffff, 0, 0x0f))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "read", big+1, 0, 0x1e))
+	// the conditions for the same argument are evaluated as OR
+	assert.Equal(t, killed, run(t, filter, nativeAuditArch, "write", 0, big))
+	assert.Equal(t, killed, run(t, filter, nativeAuditArch, "write", 0, 1))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "write", 0, big+1))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "close"))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "mount"))
+	assert.Equal(t, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM), run(t, filter, nativeAuditArch, "fchown"))
+
+	filter, err = Compile(p, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM), run(t, filter, nativeAuditArch, "mount"))
+	assert.Equal(t, allowed, run(t, filter, nativeAuditArch, "fchown"))
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	v, err := parseKernelVersion("6.18.44-fc-v139")
+	assert.NilError(t, err)
+	assert.Equal(t, [2]int{6, 18}, v)
+	v, err = parseKernelVersion("5.4-rc1")
+	assert.NilError(t, err)
+	assert.Equal(t, [2]int{5, 4}, v)
+	_, err = parseKernelVersion("6")
+	assert.ErrorContains(t, err, "invalid kernel version")
+}
+
+func TestFilterExcludes(t *testing.T) {
+	kernel := [2]int{6, 1}
+	caps := []string{"CAP_SYS_ADMIN"}
+	testCases := []struct {
+		filter   *Filter
+		expected bool
+	}{
+		{nil, false},
+		{&Filter{}, false},
+		// any of the caps
+		{&Filter{Caps: []string{"CAP_SYS_ADMIN", "CAP_SYS_PTRACE"}}, true},
+		{&Filter{Caps: []string{"CAP_SYS_PTRACE"}}, false},
+		// any of the arches
+		{&Filter{Arches: []string{"not_an_arch", runtime.GOARCH}}, true},
+		{&Filter{Arches: []string{"not_an_arch"}}, false},
+		{&Filter{MinKernel: "5.10"}, true},
+		{&Filter{MinKernel: "6.2"}, false},
+		// any of the conditions
+		{&Filter{Caps: []string{"CAP_SYS_PTRACE"}, Arches: []string{runtime.GOARCH}}, true},
+		{&Filter{Caps: []string{"CAP_SYS_PTRACE"}, MinKernel: "6.2"}, false},
+	}
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.filter.excludes(kernel, caps), "%d: %+v", i, tc.filter)
+	}
+	// the includes filter requires all the conditions
+	assert.Assert(t, !(&Filter{Caps: []string{"CAP_SYS_ADMIN", "CAP_SYS_PTRACE"}}).includes(kernel, caps))
+	assert.Assert(t, (&Filter{Caps: []string{"CAP_SYS_ADMIN"}, MinKernel: "5.10"}).includes(kernel, caps))
+}
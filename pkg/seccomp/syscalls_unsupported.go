@@ -0,0 +1,11 @@
+//go:build linux && !amd64 && !arm64 && !s390x && !ppc64le && !riscv64 && !arm
+
+package seccomp
+
+const (
+	nativeAuditArch = 0
+	nativeBigEndian = false
+)
+
+// syscalls is nil for the unsupported architectures
+var syscalls map[string]uint32
@@ -0,0 +1,35 @@
+package signal
+
+import (
+	"syscall"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSignal(t *testing.T) {
+	testCases := []struct {
+		raw         string
+		expected    syscall.Signal
+		expectedErr string
+	}{
+		{raw: "SIGTERM", expected: syscall.SIGTERM},
+		{raw: "term", expected: syscall.SIGTERM},
+		{raw: "9", expected: syscall.SIGKILL},
+		{raw: "RTMAX", expected: sigrtmax},
+		{raw: "1", expected: syscall.SIGHUP},
+		{raw: "0", expectedErr: "invalid signal: 0"},
+		{raw: "-9", expectedErr: "invalid signal: -9"},
+		{raw: "200", expectedErr: "invalid signal: 200"},
+		{raw: "SIGFOO", expectedErr: "invalid signal: SIGFOO"},
+	}
+	for _, tc := range testCases {
+		s, err := ParseSignal(tc.raw)
+		if tc.expectedErr != "" {
+			assert.ErrorContains(t, err, tc.expectedErr)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, tc.expected, s)
+	}
+}
@@ -13,6 +13,13 @@ import (
 // ForwardAllSignals forwards signals.
 // Based on https://github.com/docker/cli/blob/ef2f64abbd37edfa148f745fa0013731b5074d1b/cli/command/container/tty.go#L99-L126
 func ForwardAllSignals(ctx context.Context, pid int) chan os.Signal {
+	return ForwardAllSignalsWithHandler(ctx, pid, nil)
+}
+
+// ForwardAllSignalsWithHandler is similar to ForwardAllSignals, but the signals
+// are not forwarded when handler returns true.
+// handler can be nil.
+func ForwardAllSignalsWithHandler(ctx context.Context, pid int, handler func(os.Signal) bool) chan os.Signal {
 	sigc := make(chan os.Signal, 128)
 	signal.CatchAll(sigc)
 	go func() {
@@ -20,6 +27,9 @@ func ForwardAllSignals(ctx context.Context, pid int) chan os.Signal {
 			if s == unix.SIGCHLD || s == unix.SIGPIPE || s == unix.SIGURG {
 				continue
 			}
+			if handler != nil && handler(s) {
+				continue
+			}
 			us, ok := s.(unix.Signal)
 			if !ok {
 				logrus.Warnf("Unsupported signal %v", s)
@@ -0,0 +1,181 @@
+// Package stdcopy multiplexes stdin, stdout, stderr, and the exit code over a single stream.
+//
+// Each frame consists of a 1-byte stream type, 3 bytes of padding, a uint32be payload length, and the payload.
+// This layout is similar to the one used by Docker, but the stream types are not compatible.
+package stdcopy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+type StreamType byte
+
+const (
+	Stdin  StreamType = 0
+	Stdout StreamType = 1
+	Stderr StreamType = 2
+	// ExitCode frame carries the decimal exit code text. Sent only once, as the last frame.
+	ExitCode StreamType = 3
+)
+
+const (
+	headerLen = 8
+	// MaxPayloadLength is the maximum length of a payload.
+	MaxPayloadLength = 32 * 1024
+)
+
+func (t StreamType) String() string {
+	switch t {
+	case Stdin:
+		return "stdin"
+	case Stdout:
+		return "stdout"
+	case Stderr:
+		return "stderr"
+	case ExitCode:
+		return "exitcode"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(t))
+	}
+}
+
+// Muxer writes frames to the underlying writer.
+// Muxer is thread-safe.
+type Muxer struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewMuxer(w io.Writer) *Muxer {
+	return &Muxer{w: w}
+}
+
+// WriteFrame writes a single frame.
+// A zero-length Stdin frame stands for EOF.
+func (m *Muxer) WriteFrame(t StreamType, p []byte) error {
+	if len(p) > MaxPayloadLength {
+		return fmt.Errorf("bad payload length: %d (max: %d)", len(p), MaxPayloadLength)
+	}
+	b := make([]byte, headerLen+len(p))
+	b[0] = byte(t)
+	binary.BigEndian.PutUint32(b[4:headerLen], uint32(len(p)))
+	copy(b[headerLen:], p)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.w.Write(b)
+	return err
+}
+
+// WriteExitCode writes the ExitCode frame.
+func (m *Muxer) WriteExitCode(code int) error {
+	return m.WriteFrame(ExitCode, []byte(strconv.Itoa(code)))
+}
+
+// CloseStdin writes an empty Stdin frame.
+func (m *Muxer) CloseStdin() error {
+	return m.WriteFrame(Stdin, nil)
+}
+
+// Writer returns an io.Writer that writes frames of the stream type t.
+func (m *Muxer) Writer(t StreamType) io.Writer {
+	return &writer{m: m, t: t}
+}
+
+type writer struct {
+	m *Muxer
+	t StreamType
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > MaxPayloadLength {
+			chunk = chunk[:MaxPayloadLength]
+		}
+		if err := w.m.WriteFrame(w.t, chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// ReadFrame reads a single frame.
+func ReadFrame(r io.Reader) (StreamType, []byte, error) {
+	hdr := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	t := StreamType(hdr[0])
+	l := binary.BigEndian.Uint32(hdr[4:headerLen])
+	if l > MaxPayloadLength {
+		return t, nil, fmt.Errorf("bad payload length: %d (max: %d)", l, MaxPayloadLength)
+	}
+	p := make([]byte, l)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return t, nil, err
+	}
+	return t, p, nil
+}
+
+// ErrNoExitCode is returned by Demux when the stream ended without the ExitCode frame.
+var ErrNoExitCode = errors.New("stream ended without exit code")
+
+// Demux reads frames from r and writes the payloads to stdout and stderr,
+// until the ExitCode frame is received.
+func Demux(r io.Reader, stdout, stderr io.Writer) (int, error) {
+	for {
+		t, p, err := ReadFrame(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, ErrNoExitCode
+			}
+			return 0, err
+		}
+		switch t {
+		case Stdout:
+			if _, err := stdout.Write(p); err != nil {
+				return 0, err
+			}
+		case Stderr:
+			if _, err := stderr.Write(p); err != nil {
+				return 0, err
+			}
+		case ExitCode:
+			code, err := strconv.Atoi(string(p))
+			if err != nil {
+				return 0, fmt.Errorf("bad exit code %q: %w", string(p), err)
+			}
+			return code, nil
+		default:
+			return 0, fmt.Errorf("unexpected stream type %s", t)
+		}
+	}
+}
+
+// DemuxStdin reads Stdin frames from r and writes the payloads to stdin, until an empty Stdin frame is received.
+// stdin is not closed.
+func DemuxStdin(r io.Reader, stdin io.Writer) error {
+	for {
+		t, p, err := ReadFrame(r)
+		if err != nil {
+			return err
+		}
+		if t != Stdin {
+			return fmt.Errorf("unexpected stream type %s", t)
+		}
+		if len(p) == 0 {
+			return nil
+		}
+		if _, err := stdin.Write(p); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package stdcopy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDemux(t *testing.T) {
+	var b bytes.Buffer
+	m := NewMuxer(&b)
+	_, err := m.Writer(Stdout).Write([]byte("hello "))
+	assert.NilError(t, err)
+	_, err = m.Writer(Stderr).Write([]byte("oops"))
+	assert.NilError(t, err)
+	large := strings.Repeat("x", MaxPayloadLength+1)
+	_, err = m.Writer(Stdout).Write([]byte(large))
+	assert.NilError(t, err)
+	assert.NilError(t, m.WriteExitCode(42))
+
+	var stdout, stderr bytes.Buffer
+	code, err := Demux(&b, &stdout, &stderr)
+	assert.NilError(t, err)
+	assert.Equal(t, 42, code)
+	assert.Equal(t, "hello "+large, stdout.String())
+	assert.Equal(t, "oops", stderr.String())
+}
+
+func TestDemuxWithoutExitCode(t *testing.T) {
+	var b bytes.Buffer
+	m := NewMuxer(&b)
+	_, err := m.Writer(Stdout).Write([]byte("hello"))
+	assert.NilError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	_, err = Demux(&b, &stdout, &stderr)
+	assert.ErrorIs(t, err, ErrNoExitCode)
+}
+
+func TestDemuxStdin(t *testing.T) {
+	var b bytes.Buffer
+	m := NewMuxer(&b)
+	_, err := m.Writer(Stdin).Write([]byte("foo"))
+	assert.NilError(t, err)
+	assert.NilError(t, m.CloseStdin())
+
+	var stdin bytes.Buffer
+	assert.NilError(t, DemuxStdin(&b, &stdin))
+	assert.Equal(t, "foo", stdin.String())
+}
@@ -0,0 +1,183 @@
+// Package notify implements the client and the forwarder of the sd_notify(3) protocol.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// EnvSocket is the environment variable that contains the path of the notification socket.
+	EnvSocket = "NOTIFY_SOCKET"
+
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status returns "STATUS=<s>".
+func Status(s string) string {
+	return "STATUS=" + s
+}
+
+// Notifier sends notifications to the service manager.
+type Notifier struct {
+	addr *net.UnixAddr
+}
+
+// FromEnv returns a Notifier for $NOTIFY_SOCKET.
+// FromEnv returns nil if $NOTIFY_SOCKET is not set.
+func FromEnv() *Notifier {
+	path := os.Getenv(EnvSocket)
+	if path == "" {
+		return nil
+	}
+	// abstract socket
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+	return &Notifier{
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"},
+	}
+}
+
+// Notify sends the newline-separated states.
+// Notify is a no-op when n is nil.
+func (n *Notifier) Notify(states ...string) error {
+	if n == nil || len(states) == 0 {
+		return nil
+	}
+	conn, err := net.DialUnix(n.addr.Net, nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(strings.Join(states, "\n")))
+	return err
+}
+
+// WatchdogInterval returns the interval of the watchdog specified in $WATCHDOG_USEC.
+// WatchdogInterval returns 0 if the watchdog is not enabled for the current process.
+func WatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q: %w", pidStr, err)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q: %w", usecStr, err)
+	}
+	if usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usecStr)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// RunWatchdog calls check at the half of interval, and sends WATCHDOG=1 when check succeeds, until stop is closed.
+// check is called with a context that times out at the half of interval.
+func (n *Notifier) RunWatchdog(interval time.Duration, stop <-chan struct{}, check func(context.Context) error) {
+	if n == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval/2)
+			err := check(ctx)
+			cancel()
+			if err != nil {
+				logrus.WithError(err).Warn("health check failed, not sending the watchdog notification")
+				continue
+			}
+			if err := n.Notify(Watchdog); err != nil {
+				logrus.WithError(err).Warn("failed to send the watchdog notification")
+			}
+		}
+	}
+}
+
+// Forwarder receives notifications on a socket, typically from the child.
+//
+// READY=1 is not forwarded to the Notifier, but can be waited with Ready().
+// STATUS= is forwarded to the Notifier.
+// Other states are ignored.
+type Forwarder struct {
+	conn      *net.UnixConn
+	notifier  *Notifier
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewForwarder creates a Forwarder listening on path.
+func NewForwarder(path string, notifier *Notifier) (*Forwarder, error) {
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	f := &Forwarder{
+		conn:     conn,
+		notifier: notifier,
+		ready:    make(chan struct{}),
+	}
+	go f.loop()
+	return f, nil
+}
+
+func (f *Forwarder) loop() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.WithError(err).Warn("failed to read the notification socket")
+			}
+			return
+		}
+		for _, state := range strings.Split(string(buf[:n]), "\n") {
+			switch {
+			case state == Ready:
+				f.readyOnce.Do(func() { close(f.ready) })
+			case strings.HasPrefix(state, "STATUS="):
+				if err := f.notifier.Notify(state); err != nil {
+					logrus.WithError(err).Warn("failed to forward the status notification")
+				}
+			case state != "":
+				logrus.Debugf("Ignoring notification %q", state)
+			}
+		}
+	}
+}
+
+// Ready is closed when READY=1 is received.
+func (f *Forwarder) Ready() <-chan struct{} {
+	return f.ready
+}
+
+func (f *Forwarder) Close() error {
+	return f.conn.Close()
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestForwarder(t *testing.T) {
+	d := t.TempDir()
+	upstreamPath := filepath.Join(d, "upstream.sock")
+	upstream, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: upstreamPath, Net: "unixgram"})
+	assert.NilError(t, err)
+	defer upstream.Close()
+	t.Setenv(EnvSocket, upstreamPath)
+
+	f, err := NewForwarder(filepath.Join(d, "forward.sock"), FromEnv())
+	assert.NilError(t, err)
+	defer f.Close()
+
+	t.Setenv(EnvSocket, filepath.Join(d, "forward.sock"))
+	assert.NilError(t, FromEnv().Notify(Status("starting"), "MAINPID=42"))
+	buf := make([]byte, 4096)
+	assert.NilError(t, upstream.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := upstream.Read(buf)
+	assert.NilError(t, err)
+	assert.Equal(t, "STATUS=starting", string(buf[:n]))
+
+	assert.NilError(t, FromEnv().Notify(Ready))
+	select {
+	case <-f.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	upstreamPath := filepath.Join(t.TempDir(), "upstream.sock")
+	upstream, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: upstreamPath, Net: "unixgram"})
+	assert.NilError(t, err)
+	defer upstream.Close()
+	t.Setenv(EnvSocket, upstreamPath)
+
+	var checks atomic.Int32
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		FromEnv().RunWatchdog(20*time.Millisecond, stop, func(context.Context) error {
+			// the first check fails
+			if checks.Add(1) == 1 {
+				return errors.New("unhealthy")
+			}
+			return nil
+		})
+		close(done)
+	}()
+	buf := make([]byte, 4096)
+	assert.NilError(t, upstream.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := upstream.Read(buf)
+	assert.NilError(t, err)
+	assert.Equal(t, Watchdog, string(buf[:n]))
+	// WATCHDOG=1 is not sent for the failed check
+	assert.Assert(t, checks.Load() >= 2)
+	close(stop)
+	<-done
+}